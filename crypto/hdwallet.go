@@ -0,0 +1,165 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"github.com/tyler-smith/go-bip39"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HardenedOffset is added to a child index to derive a hardened key.
+	// ed25519 only supports hardened derivation (SLIP-0010).
+	HardenedOffset = 0x80000000
+
+	// CoinType is the SLIP-0044 coin type used in derivation paths.
+	CoinType = 1
+
+	MnemonicEntropyBits = 256
+)
+
+var masterKeySecret = []byte("ed25519 seed")
+
+// NewMnemonic returns a new BIP-39 mnemonic phrase. The entropy must be a
+// multiple of 32 bits between 128 and 256.
+func NewMnemonic(entropyBits int) (string, error) {
+	entropy, err := bip39.NewEntropy(entropyBits)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+func ValidateMnemonic(mnemonic string) error {
+	_, err := bip39.EntropyFromMnemonic(mnemonic)
+
+	return err
+}
+
+// SeedFromMnemonic returns the BIP-39 seed of the mnemonic. The passphrase is
+// optional and yields an entirely different wallet when changed.
+func SeedFromMnemonic(mnemonic string, passphrase string) ([]byte, error) {
+	return bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+}
+
+// DerivationPath returns the path of the key at the given index of an account.
+func DerivationPath(account uint32, index uint32) string {
+	return fmt.Sprintf("m/44'/%d'/%d'/0'/%d'", CoinType, account, index)
+}
+
+// ParseDerivationPath parses paths like m/44'/1'/0'/0'/0'. Every element must
+// be hardened.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path [%s] must start with m", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		value, hardened := strings.CutSuffix(part, "'")
+		if !hardened {
+			return nil, fmt.Errorf("derivation path [%s] has non-hardened element [%s]", path, part)
+		}
+
+		index, err := strconv.ParseUint(value, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("derivation path [%s] has invalid element [%s]", path, part)
+		}
+		indexes = append(indexes, uint32(index)+HardenedOffset)
+	}
+
+	return indexes, nil
+}
+
+// HDKey is a node in a SLIP-0010 ed25519 key tree.
+type HDKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+func NewMasterKey(seed []byte) *HDKey {
+	return newHDKey(masterKeySecret, seed)
+}
+
+func newHDKey(secret []byte, data []byte) *HDKey {
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	return &HDKey{
+		key:       sum[:SeedLen],
+		chainCode: sum[SeedLen:],
+	}
+}
+
+// Derive returns the hardened child at the given index.
+func (k *HDKey) Derive(index uint32) (*HDKey, error) {
+	if index < HardenedOffset {
+		return nil, fmt.Errorf("ed25519 only supports hardened derivation (index %d)", index)
+	}
+
+	data := make([]byte, 0, 1+SeedLen+4)
+	data = append(data, 0x00)
+	data = append(data, k.key...)
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	return newHDKey(k.chainCode, data), nil
+}
+
+func (k *HDKey) DerivePath(path string) (*HDKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, index := range indexes {
+		key, err = key.Derive(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func (k *HDKey) ChainCode() []byte {
+	return k.chainCode
+}
+
+func (k *HDKey) PrivateKey() *PrivateKey {
+	return NewPrivateKeyFromSeed(k.key)
+}
+
+// Wallet derives all keys of a user from a single mnemonic phrase.
+type Wallet struct {
+	master *HDKey
+}
+
+func NewWallet(mnemonic string, passphrase string) (*Wallet, error) {
+	seed, err := SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{master: NewMasterKey(seed)}, nil
+}
+
+func (w *Wallet) Derive(path string) (*PrivateKey, error) {
+	key, err := w.master.DerivePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.PrivateKey(), nil
+}
+
+// Key returns the key at the given index of the first account.
+func (w *Wallet) Key(index uint32) (*PrivateKey, error) {
+	return w.Derive(DerivationPath(0, index))
+}
@@ -0,0 +1,76 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSeedFromMnemonic(t *testing.T) {
+	var (
+		mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+		seedHex  = "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	)
+
+	seed, err := SeedFromMnemonic(mnemonic, "TREZOR")
+	require.Nil(t, err)
+	assert.Equal(t, seedHex, hex.EncodeToString(seed))
+
+	_, err = SeedFromMnemonic("abandon abandon abandon", "")
+	assert.NotNil(t, err)
+}
+
+func TestNewMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic(MnemonicEntropyBits)
+	require.Nil(t, err)
+	assert.Nil(t, ValidateMnemonic(mnemonic))
+
+	first, err := NewWallet(mnemonic, "")
+	require.Nil(t, err)
+	second, err := NewWallet(mnemonic, "")
+	require.Nil(t, err)
+
+	for i := uint32(0); i < 3; i++ {
+		a, err := first.Key(i)
+		require.Nil(t, err)
+		b, err := second.Key(i)
+		require.Nil(t, err)
+		assert.Equal(t, a.Public().Address(), b.Public().Address())
+	}
+
+	a, _ := first.Key(0)
+	b, _ := first.Key(1)
+	assert.NotEqual(t, a.Public().Address(), b.Public().Address())
+}
+
+// Test vector 1 for ed25519 from SLIP-0010.
+func TestHDKeyDerive(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master := NewMasterKey(seed)
+
+	assert.Equal(t, "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb", hex.EncodeToString(master.ChainCode()))
+	assert.Equal(t, "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", hex.EncodeToString(master.PrivateKey().Seed()))
+	assert.Equal(t, "a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed", hex.EncodeToString(master.PrivateKey().Public().Bytes()))
+
+	child, err := master.DerivePath("m/0'")
+	require.Nil(t, err)
+	assert.Equal(t, "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69", hex.EncodeToString(child.ChainCode()))
+	assert.Equal(t, "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", hex.EncodeToString(child.PrivateKey().Seed()))
+
+	_, err = master.DerivePath("m/0")
+	assert.NotNil(t, err)
+	_, err = master.Derive(1)
+	assert.NotNil(t, err)
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	indexes, err := ParseDerivationPath(DerivationPath(2, 5))
+	require.Nil(t, err)
+	assert.Equal(t, []uint32{44 + HardenedOffset, CoinType + HardenedOffset, 2 + HardenedOffset, HardenedOffset, 5 + HardenedOffset}, indexes)
+
+	_, err = ParseDerivationPath("44'/0'")
+	assert.NotNil(t, err)
+	_, err = ParseDerivationPath("m/x'")
+	assert.NotNil(t, err)
+}
@@ -3,7 +3,9 @@ package crypto
 import (
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
 )
 
@@ -13,6 +15,13 @@ const (
 	PublicKeyLen  = 32
 	SeedLen       = 32
 	AddressLen    = 20
+
+	// AddressVersion is hashed together with the public key and encoded as the
+	// first symbol of the address string.
+	AddressVersion = 0
+
+	MainnetPrefix = "blk"
+	TestnetPrefix = "tblk"
 )
 
 type PrivateKey struct {
@@ -59,6 +68,10 @@ func (p *PrivateKey) Bytes() []byte {
 	return p.key
 }
 
+func (p *PrivateKey) Seed() []byte {
+	return p.key.Seed()
+}
+
 func (p *PrivateKey) Sign(msg []byte) *Signature {
 	return &Signature{value: ed25519.Sign(p.key, msg)}
 }
@@ -83,7 +96,9 @@ func PublicKeyFromBytes(b []byte) *PublicKey {
 }
 
 func (p *PublicKey) Address() Address {
-	return Address{value: p.key[len(p.key)-AddressLen:]}
+	hash := sha256.Sum256(append([]byte{AddressVersion}, p.key...))
+
+	return Address{value: hash[:AddressLen]}
 }
 
 func (p *PublicKey) Bytes() []byte {
@@ -115,17 +130,76 @@ type Address struct {
 }
 
 func AddressFromBytes(b []byte) Address {
-	if len(b) != AddressLen {
-		panic("invalid address length")
+	if err := ValidateAddressBytes(b); err != nil {
+		panic(err)
 	}
 
 	return Address{value: b}
 }
 
+func ValidateAddressBytes(b []byte) error {
+	if len(b) != AddressLen {
+		return fmt.Errorf("invalid address length (%d)", len(b))
+	}
+
+	return nil
+}
+
+// ParseAddress decodes a mainnet address string.
+func ParseAddress(s string) (Address, error) {
+	return DecodeAddress(MainnetPrefix, s)
+}
+
+func ValidateAddress(s string) error {
+	_, err := ParseAddress(s)
+
+	return err
+}
+
+// DecodeAddress decodes an address string and checks its network prefix,
+// version and checksum.
+func DecodeAddress(prefix string, s string) (Address, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return Address{}, err
+	}
+
+	if hrp != prefix {
+		return Address{}, fmt.Errorf("address [%s] has prefix [%s], expected [%s]", s, hrp, prefix)
+	}
+	if len(data) == 0 || data[0] != AddressVersion {
+		return Address{}, fmt.Errorf("address [%s] has unsupported version", s)
+	}
+
+	b, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return Address{}, err
+	}
+	if err := ValidateAddressBytes(b); err != nil {
+		return Address{}, err
+	}
+
+	return Address{value: b}, nil
+}
+
+// Encode returns the bech32m encoding of the address with the network prefix.
+func (a Address) Encode(prefix string) string {
+	data, err := convertBits(a.value, 8, 5, true)
+	if err != nil {
+		panic(err)
+	}
+
+	return bech32Encode(prefix, append([]byte{AddressVersion}, data...))
+}
+
 func (a Address) Bytes() []byte {
 	return a.value
 }
 
 func (a Address) String() string {
+	return a.Encode(MainnetPrefix)
+}
+
+func (a Address) Hex() string {
 	return hex.EncodeToString(a.value)
 }
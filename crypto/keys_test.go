@@ -2,6 +2,8 @@ package crypto
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
 )
 
@@ -38,10 +40,72 @@ func TestNewPrivateKeyFromString(t *testing.T) {
 	var (
 		seed       = "c96e14d8abd284946d6f8bd3fabe5d4e4a22fd63013382b040b247ec1a471060"
 		privateKey = NewPrivateKeyFromString(seed)
-		addressStr = "5955d2b0bbad576748b1dc3d499b57b517e2c144"
+		addressStr = "blk1qv9hlwvlap7qetsk2zysr0y8qgv8ne3js89tw9z"
 	)
 
 	assert.Equal(t, PrivateKeyLen, len(privateKey.Bytes()))
 	address := privateKey.Public().Address()
 	assert.Equal(t, addressStr, address.String())
 }
+
+func TestParseAddress(t *testing.T) {
+	address := GeneratePrivateKey().Public().Address()
+
+	parsed, err := ParseAddress(address.String())
+	require.Nil(t, err)
+	assert.Equal(t, address.Bytes(), parsed.Bytes())
+
+	parsed, err = ParseAddress(strings.ToUpper(address.String()))
+	require.Nil(t, err)
+	assert.Equal(t, address.Bytes(), parsed.Bytes())
+
+	_, err = ParseAddress(address.Encode(TestnetPrefix))
+	assert.NotNil(t, err)
+
+	parsed, err = DecodeAddress(TestnetPrefix, address.Encode(TestnetPrefix))
+	require.Nil(t, err)
+	assert.Equal(t, address.Bytes(), parsed.Bytes())
+
+	_, err = ParseAddress(address.Hex())
+	assert.NotNil(t, err)
+}
+
+func TestParseAddressTypo(t *testing.T) {
+	s := GeneratePrivateKey().Public().Address().String()
+
+	for i := len(MainnetPrefix) + 1; i < len(s); i++ {
+		typo := []byte(s)
+		if typo[i] == 'q' {
+			typo[i] = 'p'
+		} else {
+			typo[i] = 'q'
+		}
+
+		assert.NotNil(t, ValidateAddress(string(typo)))
+	}
+}
+
+func TestBech32m(t *testing.T) {
+	valid := []string{
+		"A1LQFN3A",
+		"a1lqfn3a",
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+		"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+	}
+	for _, s := range valid {
+		hrp, data, err := bech32Decode(s)
+		require.Nil(t, err, s)
+		assert.Equal(t, strings.ToLower(s), bech32Encode(hrp, data))
+	}
+
+	invalid := []string{
+		"A1G7SGD8",
+		"abc1rzg",
+		"1qzzfhee",
+		"a1lqfn3A",
+	}
+	for _, s := range invalid {
+		_, _, err := bech32Decode(s)
+		assert.NotNil(t, err, s)
+	}
+}
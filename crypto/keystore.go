@@ -0,0 +1,320 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	KeystoreVersion = 1
+
+	// StandardScryptN takes about a second to unlock on a modern machine,
+	// LightScryptN is meant for tests and throwaway keys.
+	StandardScryptN = 1 << 18
+	LightScryptN    = 1 << 12
+
+	scryptR     = 8
+	scryptP     = 1
+	scryptDKLen = 32
+	saltLen     = 32
+
+	// maxScryptN and maxScryptRP bound the work and memory a keystore file
+	// may ask for before it is unlocked.
+	maxScryptN  = 1 << 20
+	maxScryptRP = 16
+)
+
+type KeystoreFile struct {
+	Version int          `json:"version"`
+	Address string       `json:"address"`
+	Crypto  CryptoParams `json:"crypto"`
+}
+
+type CryptoParams struct {
+	Cipher     string       `json:"cipher"`
+	CipherText string       `json:"ciphertext"`
+	Nonce      string       `json:"nonce"`
+	KDF        string       `json:"kdf"`
+	KDFParams  ScryptParams `json:"kdfparams"`
+}
+
+type ScryptParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// EncryptKey seals the seed of the private key with a key derived from the
+// passphrase and returns the JSON encoded keystore file.
+func EncryptKey(key *PrivateKey, passphrase string, scryptN int) ([]byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	params := ScryptParams{
+		N:     scryptN,
+		R:     scryptR,
+		P:     scryptP,
+		DKLen: scryptDKLen,
+		Salt:  hex.EncodeToString(salt),
+	}
+	aead, err := newKeystoreCipher(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	address := key.Public().Address()
+	ciphertext := aead.Seal(nil, nonce, key.Seed(), address.Bytes())
+
+	return json.MarshalIndent(KeystoreFile{
+		Version: KeystoreVersion,
+		Address: address.String(),
+		Crypto: CryptoParams{
+			Cipher:     "aes-256-gcm",
+			CipherText: hex.EncodeToString(ciphertext),
+			Nonce:      hex.EncodeToString(nonce),
+			KDF:        "scrypt",
+			KDFParams:  params,
+		},
+	}, "", "  ")
+}
+
+// DecryptKey opens a JSON encoded keystore file with the passphrase.
+func DecryptKey(data []byte, passphrase string) (*PrivateKey, error) {
+	var file KeystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	if file.Version != KeystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version (%d)", file.Version)
+	}
+	if file.Crypto.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported keystore cipher [%s]", file.Crypto.Cipher)
+	}
+	if file.Crypto.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported keystore kdf [%s]", file.Crypto.KDF)
+	}
+
+	aead, err := newKeystoreCipher(passphrase, file.Crypto.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := hex.DecodeString(file.Crypto.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid keystore nonce length")
+	}
+
+	ciphertext, err := hex.DecodeString(file.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := ParseAddress(file.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := aead.Open(nil, nonce, ciphertext, address.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt key with given passphrase")
+	}
+	if len(seed) != SeedLen {
+		return nil, fmt.Errorf("invalid keystore seed length")
+	}
+
+	key := NewPrivateKeyFromSeed(seed)
+	if key.Public().Address().String() != address.String() {
+		return nil, fmt.Errorf("keystore key does not match address [%s]", address)
+	}
+
+	return key, nil
+}
+
+func validateScryptParams(params ScryptParams) error {
+	if params.N <= 1 || params.N > maxScryptN || params.N&(params.N-1) != 0 {
+		return fmt.Errorf("invalid keystore scrypt n (%d)", params.N)
+	}
+	if params.R < 1 || params.P < 1 || params.R > maxScryptRP/params.P {
+		return fmt.Errorf("invalid keystore scrypt r (%d) and p (%d)", params.R, params.P)
+	}
+
+	return nil
+}
+
+func newKeystoreCipher(passphrase string, params ScryptParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.DKLen != scryptDKLen {
+		return nil, fmt.Errorf("invalid keystore key length (%d)", params.DKLen)
+	}
+	if err := validateScryptParams(params); err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// LoadKeyFile reads and decrypts a single keystore file.
+func LoadKeyFile(path string, passphrase string) (*PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptKey(data, passphrase)
+}
+
+// Keystore keeps encrypted keys as one JSON file per address in a directory.
+type Keystore struct {
+	dir     string
+	scryptN int
+}
+
+func NewKeystore(dir string, scryptN int) (*Keystore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Keystore{
+		dir:     dir,
+		scryptN: scryptN,
+	}, nil
+}
+
+func (k *Keystore) path(address Address) string {
+	return filepath.Join(k.dir, address.String()+".json")
+}
+
+func (k *Keystore) write(key *PrivateKey, passphrase string) (Address, error) {
+	data, err := EncryptKey(key, passphrase, k.scryptN)
+	if err != nil {
+		return Address{}, err
+	}
+
+	address := key.Public().Address()
+	tmp := k.path(address) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return Address{}, err
+	}
+
+	return address, os.Rename(tmp, k.path(address))
+}
+
+// Create generates a new key and stores it encrypted with the passphrase.
+func (k *Keystore) Create(passphrase string) (Address, error) {
+	return k.write(GeneratePrivateKey(), passphrase)
+}
+
+// ImportKey stores an existing key encrypted with the passphrase.
+func (k *Keystore) ImportKey(key *PrivateKey, passphrase string) (Address, error) {
+	if _, err := os.Stat(k.path(key.Public().Address())); err == nil {
+		return Address{}, fmt.Errorf("key [%s] already exists", key.Public().Address())
+	}
+
+	return k.write(key, passphrase)
+}
+
+// Import stores a keystore file created elsewhere, re-encrypted under a new
+// passphrase.
+func (k *Keystore) Import(data []byte, passphrase string, newPassphrase string) (Address, error) {
+	key, err := DecryptKey(data, passphrase)
+	if err != nil {
+		return Address{}, err
+	}
+
+	return k.ImportKey(key, newPassphrase)
+}
+
+// Export returns the keystore file of the address encrypted with a new
+// passphrase, so it can be moved to another keystore.
+func (k *Keystore) Export(address Address, passphrase string, newPassphrase string) ([]byte, error) {
+	key, err := k.Unlock(address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptKey(key, newPassphrase, k.scryptN)
+}
+
+// Unlock decrypts the key of the address.
+func (k *Keystore) Unlock(address Address, passphrase string) (*PrivateKey, error) {
+	return LoadKeyFile(k.path(address), passphrase)
+}
+
+func (k *Keystore) ChangePassphrase(address Address, passphrase string, newPassphrase string) error {
+	key, err := k.Unlock(address, passphrase)
+	if err != nil {
+		return err
+	}
+
+	_, err = k.write(key, newPassphrase)
+
+	return err
+}
+
+func (k *Keystore) Delete(address Address, passphrase string) error {
+	if _, err := k.Unlock(address, passphrase); err != nil {
+		return err
+	}
+
+	return os.Remove(k.path(address))
+}
+
+// Addresses lists the addresses of all keys in the keystore.
+func (k *Keystore) Addresses() ([]Address, error) {
+	entries, err := os.ReadDir(k.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []Address
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+
+		address, err := ParseAddress(name)
+		if err != nil {
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEncryptDecryptKey(t *testing.T) {
+	privateKey := GeneratePrivateKey()
+
+	data, err := EncryptKey(privateKey, "secret", LightScryptN)
+	require.Nil(t, err)
+
+	decrypted, err := DecryptKey(data, "secret")
+	require.Nil(t, err)
+	assert.Equal(t, privateKey.Bytes(), decrypted.Bytes())
+
+	_, err = DecryptKey(data, "wrong")
+	assert.NotNil(t, err)
+}
+
+func TestDecryptKeyScryptParams(t *testing.T) {
+	data, err := EncryptKey(GeneratePrivateKey(), "secret", LightScryptN)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name string
+		n    int
+		r    int
+		p    int
+	}{
+		{"n too large", 1 << 30, scryptR, scryptP},
+		{"n not a power of two", LightScryptN + 1, scryptR, scryptP},
+		{"n of one", 1, scryptR, scryptP},
+		{"r times p too large", LightScryptN, 1 << 30, 1 << 30},
+		{"zero p", LightScryptN, scryptR, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var file KeystoreFile
+			require.Nil(t, json.Unmarshal(data, &file))
+			file.Crypto.KDFParams.N = test.n
+			file.Crypto.KDFParams.R = test.r
+			file.Crypto.KDFParams.P = test.p
+			tampered, err := json.Marshal(file)
+			require.Nil(t, err)
+
+			_, err = DecryptKey(tampered, "secret")
+			assert.ErrorContains(t, err, "scrypt")
+		})
+	}
+
+	_, err = EncryptKey(GeneratePrivateKey(), "secret", maxScryptN*2)
+	assert.NotNil(t, err)
+}
+
+func TestKeystore(t *testing.T) {
+	keystore, err := NewKeystore(t.TempDir(), LightScryptN)
+	require.Nil(t, err)
+
+	address, err := keystore.Create("secret")
+	require.Nil(t, err)
+
+	privateKey, err := keystore.Unlock(address, "secret")
+	require.Nil(t, err)
+	assert.Equal(t, address, privateKey.Public().Address())
+
+	_, err = keystore.Unlock(address, "wrong")
+	assert.NotNil(t, err)
+
+	require.Nil(t, keystore.ChangePassphrase(address, "secret", "new secret"))
+	_, err = keystore.Unlock(address, "secret")
+	assert.NotNil(t, err)
+	_, err = keystore.Unlock(address, "new secret")
+	assert.Nil(t, err)
+
+	addresses, err := keystore.Addresses()
+	require.Nil(t, err)
+	assert.Equal(t, []Address{address}, addresses)
+}
+
+func TestKeystoreImportExport(t *testing.T) {
+	source, err := NewKeystore(t.TempDir(), LightScryptN)
+	require.Nil(t, err)
+	target, err := NewKeystore(t.TempDir(), LightScryptN)
+	require.Nil(t, err)
+
+	privateKey := GeneratePrivateKey()
+	address, err := source.ImportKey(privateKey, "secret")
+	require.Nil(t, err)
+
+	_, err = source.ImportKey(privateKey, "secret")
+	assert.NotNil(t, err)
+
+	data, err := source.Export(address, "secret", "transfer")
+	require.Nil(t, err)
+
+	imported, err := target.Import(data, "transfer", "other secret")
+	require.Nil(t, err)
+	assert.Equal(t, address, imported)
+
+	unlocked, err := target.Unlock(address, "other secret")
+	require.Nil(t, err)
+	assert.Equal(t, privateKey.Bytes(), unlocked.Bytes())
+}
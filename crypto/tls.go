@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// certValidity is how long self-signed certificates are valid. Peers pin the
+// key of the certificate rather than trusting its dates.
+const certValidity = 10 * 365 * 24 * time.Hour
+
+// NewTLSCertificate returns a self-signed certificate of the key, naming the
+// hex encoded public key as its subject.
+func NewTLSCertificate(key *PrivateKey) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hex.EncodeToString(key.Public().Bytes())},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.key.Public(), key.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key.key,
+	}, nil
+}
+
+// TLSPublicKey returns the ed25519 key of a self-signed certificate after
+// checking the certificate is signed by it.
+func TLSPublicKey(cert *x509.Certificate) (*PublicKey, error) {
+	key, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("certificate key is not ed25519")
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return nil, fmt.Errorf("certificate is not self-signed: %w", err)
+	}
+
+	return PublicKeyFromBytes(key), nil
+}
+
+// NewTLSConfig returns a TLS 1.3 configuration presenting cert, if any, and
+// accepting the self-signed certificates whose key verify accepts. Servers ask
+// for a client certificate without requiring one, clients always require the
+// certificate of the server.
+func NewTLSConfig(cert *tls.Certificate, server bool, verify func(*PublicKey) error) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		// certificates are self-signed, their keys are checked below
+		InsecureSkipVerify: true,
+		ClientAuth:         tls.RequestClientCert,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				if server {
+					return nil
+				}
+				return errors.New("no certificate")
+			}
+
+			peerCert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			key, err := TLSPublicKey(peerCert)
+			if err != nil {
+				return err
+			}
+
+			return verify(key)
+		},
+	}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+
+	return cfg
+}
@@ -0,0 +1,29 @@
+package crypto
+
+import (
+	"crypto/x509"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTLSCertificate(t *testing.T) {
+	key := GeneratePrivateKey()
+	cert, err := NewTLSCertificate(key)
+	require.Nil(t, err)
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.Nil(t, err)
+	public, err := TLSPublicKey(parsed)
+	require.Nil(t, err)
+	assert.Equal(t, key.Public().Bytes(), public.Bytes())
+
+	// the certificate of another key does not pass for this one
+	other, err := NewTLSCertificate(GeneratePrivateKey())
+	require.Nil(t, err)
+	forged, err := x509.ParseCertificate(other.Certificate[0])
+	require.Nil(t, err)
+	forged.PublicKey = parsed.PublicKey
+	_, err = TLSPublicKey(forged)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,276 @@
+package light
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"sync"
+	"time"
+)
+
+const headersBatchSize = 500
+
+type Config struct {
+	// GenesisHash pins the chain to follow. When empty the genesis header
+	// served by the node is trusted.
+	GenesisHash []byte
+	// Schedule checks the signer of every header after genesis. When nil
+	// any validly signed header is accepted.
+	Schedule  Schedule
+	Addresses []crypto.Address
+	// NodeID pins the hex encoded node key of the node to follow. When empty
+	// any node key is accepted.
+	NodeID string
+}
+
+// Client follows the header chain of a node and verifies transactions with
+// merkle inclusion proofs instead of storing full blocks.
+type Client struct {
+	Config
+	client  proto.NodeClient
+	lock    sync.RWMutex
+	headers []*proto.Header
+	heights map[string]int
+	watched map[string]bool
+}
+
+func NewClient(client proto.NodeClient, cfg Config) *Client {
+	c := &Client{
+		Config:  cfg,
+		client:  client,
+		headers: []*proto.Header{},
+		heights: make(map[string]int),
+		watched: make(map[string]bool),
+	}
+	for _, address := range cfg.Addresses {
+		c.Watch(address)
+	}
+
+	return c
+}
+
+func Dial(addr string, cfg Config) (*Client, error) {
+	tlsConfig := crypto.NewTLSConfig(nil, false, func(key *crypto.PublicKey) error {
+		if cfg.NodeID != "" && hex.EncodeToString(key.Bytes()) != cfg.NodeID {
+			return fmt.Errorf("node key [%x] is not the pinned one", key.Bytes())
+		}
+		return nil
+	})
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(proto.NewNodeClient(conn), cfg), nil
+}
+
+func (c *Client) Height() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.headers) - 1
+}
+
+func (c *Client) GetHeaderByHash(hash []byte) (*proto.Header, int, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	height, ok := c.heights[hex.EncodeToString(hash)]
+	if !ok {
+		return nil, 0, fmt.Errorf("header with hash [%x] does not exist", hash)
+	}
+
+	return c.headers[height], height, nil
+}
+
+func (c *Client) Watch(address crypto.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.watched[address.String()] = true
+}
+
+func (c *Client) isWatched(address []byte) bool {
+	if crypto.ValidateAddressBytes(address) != nil {
+		return false
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.watched[crypto.AddressFromBytes(address).String()]
+}
+
+// IsRelevant reports whether the transaction pays to or spends from one of
+// the watched addresses.
+func (c *Client) IsRelevant(tx *proto.Transaction) bool {
+	for _, output := range tx.Outputs {
+		if c.isWatched(output.Address) {
+			return true
+		}
+	}
+
+	for _, input := range tx.Inputs {
+		if len(input.PublicKey) != crypto.PublicKeyLen {
+			continue
+		}
+		address := crypto.PublicKeyFromBytes(input.PublicKey).Address()
+		if c.isWatched(address.Bytes()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sync downloads and validates all headers the node has beyond our tip.
+func (c *Client) Sync(ctx context.Context) error {
+	for {
+		resp, err := c.client.GetHeaders(ctx, &proto.HeadersRequest{
+			FromHeight: int32(c.Height() + 1),
+			Limit:      headersBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(resp.Headers) == 0 {
+			return nil
+		}
+
+		for _, header := range resp.Headers {
+			if err := c.addHeader(header); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Follow keeps syncing headers every interval until the context is done.
+func (c *Client) Follow(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Sync(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) addHeader(signed *proto.SignedHeader) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.validateHeader(signed); err != nil {
+		return err
+	}
+
+	hash := types.HashHeader(signed.Header)
+	c.heights[hex.EncodeToString(hash)] = len(c.headers)
+	c.headers = append(c.headers, signed.Header)
+
+	return nil
+}
+
+func (c *Client) validateHeader(signed *proto.SignedHeader) error {
+	if signed.Header == nil {
+		return fmt.Errorf("missing header")
+	}
+
+	block := &proto.Block{
+		Header:    signed.Header,
+		PublicKey: signed.PublicKey,
+		Signature: signed.Signature,
+	}
+	if !types.VerifyBlock(block) {
+		return fmt.Errorf("invalid header signature")
+	}
+
+	var (
+		height = len(c.headers)
+		hash   = types.HashHeader(signed.Header)
+	)
+	if int(signed.Header.Height) != height {
+		return fmt.Errorf("header at height (%d) claims height (%d)", height, signed.Header.Height)
+	}
+	if height == 0 {
+		if len(c.GenesisHash) > 0 && !bytes.Equal(hash, c.GenesisHash) {
+			return fmt.Errorf("genesis hash mismatch")
+		}
+
+		return nil
+	}
+
+	prevHash := types.HashHeader(c.headers[height-1])
+	if !bytes.Equal(prevHash, signed.Header.PrevHash) {
+		return fmt.Errorf("prev header hash mismatch at height (%d)", height)
+	}
+
+	if c.Schedule != nil && !c.Schedule.IsScheduled(height, signed.PublicKey) {
+		return fmt.Errorf("header at height (%d) signed by unscheduled validator", height)
+	}
+
+	return nil
+}
+
+// VerifyTxHash checks that the transaction with the given hash is included
+// in the synced block with the given hash.
+func (c *Client) VerifyTxHash(ctx context.Context, txHash []byte, blockHash []byte) error {
+	header, _, err := c.GetHeaderByHash(blockHash)
+	if err != nil {
+		return err
+	}
+
+	proof, err := c.client.GetTxProof(ctx, &proto.TxProofRequest{
+		BlockHash: blockHash,
+		TxHash:    txHash,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(proof.TxHash, txHash) {
+		return fmt.Errorf("proof is for a different transaction")
+	}
+
+	if !types.VerifyTxProof(header.RootHash, proof) {
+		return fmt.Errorf("invalid inclusion proof for transaction [%x]", txHash)
+	}
+
+	return nil
+}
+
+// VerifyTransaction checks that the transaction concerns one of the watched
+// addresses and is included in the synced block with the given hash.
+func (c *Client) VerifyTransaction(ctx context.Context, tx *proto.Transaction, blockHash []byte) error {
+	if !c.IsRelevant(tx) {
+		return fmt.Errorf("transaction does not concern any watched address")
+	}
+
+	return c.VerifyTxHash(ctx, types.HashTransaction(tx), blockHash)
+}
+
+// Confirmations returns how many synced headers are built on top of the block
+// with the given hash, the block itself included.
+func (c *Client) Confirmations(blockHash []byte) (int, error) {
+	_, height, err := c.GetHeaderByHash(blockHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Height() - height + 1, nil
+}
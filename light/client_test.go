@@ -0,0 +1,199 @@
+package light
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/node"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/cmkqwerty/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"net"
+	"testing"
+)
+
+// serveNode serves the node over TLS on a random local port and returns a
+// client dialed with the node ID pinned.
+func serveNode(t *testing.T, n *node.Node, cfg Config) *Client {
+	cert, err := crypto.NewTLSCertificate(n.NodeKey)
+	require.Nil(t, err)
+	creds := credentials.NewTLS(crypto.NewTLSConfig(&cert, true, func(*crypto.PublicKey) error {
+		return nil
+	}))
+
+	server := grpc.NewServer(grpc.Creds(creds))
+	proto.RegisterNodeServer(server, n)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+
+	cfg.NodeID = n.ID()
+	client, err := Dial(ln.Addr().String(), cfg)
+	require.Nil(t, err)
+
+	return client
+}
+
+// genesisKey owns the output of the genesis block of node.NewChain.
+var genesisKey = crypto.NewPrivateKeyFromSeedString("d12cda4733e2e24377cc161b55bf447a13a615d48838b33ab7634b77531734dc")
+
+// payGenesis returns a transaction paying half of the genesis output to
+// recipient, the other half back to the genesis key.
+func payGenesis(t *testing.T, n *node.Node, recipient crypto.Address) *proto.Transaction {
+	unspent, err := n.ListUnspent(context.Background(), &proto.UnspentRequest{
+		Address: genesisKey.Public().Address().Bytes(),
+	})
+	require.Nil(t, err)
+	require.Len(t, unspent.Outputs, 1)
+
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{{
+			PrevTxHash: unspent.Outputs[0].TxHash,
+			PublicKey:  genesisKey.Public().Bytes(),
+		}},
+		Outputs: []*proto.TxOutput{
+			{Amount: 500, Address: recipient.Bytes()},
+			{Amount: 500, Address: genesisKey.Public().Address().Bytes()},
+		},
+	}
+	tx.Inputs[0].Signature = types.SignTransaction(genesisKey, tx).Bytes()
+
+	return tx
+}
+
+// tipHeader returns the header of the last block of the node.
+func tipHeader(t *testing.T, n *node.Node) *proto.Header {
+	status, err := n.Status()
+	require.Nil(t, err)
+	resp, err := n.GetHeaders(context.Background(), &proto.HeadersRequest{FromHeight: status.Height, Limit: 1})
+	require.Nil(t, err)
+	require.Len(t, resp.Headers, 1)
+
+	return resp.Headers[0].Header
+}
+
+func addBlock(t *testing.T, n *node.Node, privKey *crypto.PrivateKey, txx ...*proto.Transaction) *proto.Block {
+	tip := tipHeader(t, n)
+
+	block := util.RandomBlock()
+	block.Header.Height = tip.Height + 1
+	block.Header.PrevHash = types.HashHeader(tip)
+	block.Transactions = txx
+	types.SignBlock(privKey, block)
+	_, err := n.HandleBlock(context.Background(), block)
+	require.Nil(t, err)
+
+	return block
+}
+
+func TestSync(t *testing.T) {
+	var (
+		n         = node.NewNode(node.ServerConfig{})
+		validator = crypto.GeneratePrivateKey()
+		client    = serveNode(t, n, Config{})
+	)
+
+	// more than a batch of headers, synced over several pages
+	for i := 0; i < headersBatchSize+10; i++ {
+		addBlock(t, n, validator)
+	}
+
+	require.Nil(t, client.Sync(context.Background()))
+	assert.Equal(t, headersBatchSize+10, client.Height())
+
+	block := addBlock(t, n, validator)
+	require.Nil(t, client.Sync(context.Background()))
+	assert.Equal(t, int(block.Header.Height), client.Height())
+	_, height, err := client.GetHeaderByHash(types.HashBlock(block))
+	require.Nil(t, err)
+	assert.Equal(t, client.Height(), height)
+}
+
+func TestSyncGenesisMismatch(t *testing.T) {
+	client := serveNode(t, node.NewNode(node.ServerConfig{}), Config{GenesisHash: util.RandomHash()})
+
+	assert.NotNil(t, client.Sync(context.Background()))
+	assert.Equal(t, -1, client.Height())
+}
+
+func TestSyncSchedule(t *testing.T) {
+	var (
+		n         = node.NewNode(node.ServerConfig{})
+		validator = crypto.GeneratePrivateKey()
+		intruder  = crypto.GeneratePrivateKey()
+		schedule  = NewRoundRobinSchedule(validator.Public())
+		client    = serveNode(t, n, Config{Schedule: schedule})
+	)
+
+	addBlock(t, n, validator)
+	addBlock(t, n, intruder)
+
+	assert.NotNil(t, client.Sync(context.Background()))
+	assert.Equal(t, 1, client.Height())
+}
+
+func TestHeaderHeightMismatch(t *testing.T) {
+	var (
+		n         = node.NewNode(node.ServerConfig{})
+		validator = crypto.GeneratePrivateKey()
+		client    = serveNode(t, n, Config{})
+	)
+	addBlock(t, n, validator)
+	require.Nil(t, client.Sync(context.Background()))
+
+	// a validly signed header on top of the tip that skips a height
+	header := util.RandomBlock().Header
+	header.Height = int32(client.Height() + 2)
+	header.PrevHash = types.HashHeader(tipHeader(t, n))
+	block := &proto.Block{Header: header}
+	types.SignBlock(validator, block)
+
+	err := client.addHeader(&proto.SignedHeader{
+		Header:    header,
+		PublicKey: block.PublicKey,
+		Signature: block.Signature,
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, client.Height())
+}
+
+func TestVerifyTransaction(t *testing.T) {
+	var (
+		n         = node.NewNode(node.ServerConfig{})
+		validator = crypto.GeneratePrivateKey()
+		recipient = crypto.GeneratePrivateKey().Public().Address()
+		client    = serveNode(t, n, Config{Addresses: []crypto.Address{recipient}})
+	)
+
+	tx := payGenesis(t, n, recipient)
+	block := addBlock(t, n, validator, tx)
+	blockHash := types.HashBlock(block)
+
+	// other spends the change of tx, it concerns no watched address
+	other := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{{
+			PrevTxHash:   types.HashTransaction(tx),
+			PrevOutIndex: 1,
+			PublicKey:    genesisKey.Public().Bytes(),
+		}},
+		Outputs: []*proto.TxOutput{types.NewDataOutput([]byte("unrelated"))},
+	}
+	other.Inputs[0].Signature = types.SignTransaction(genesisKey, other).Bytes()
+	otherHash := types.HashBlock(addBlock(t, n, validator, other))
+
+	require.Nil(t, client.Sync(context.Background()))
+	assert.Nil(t, client.VerifyTransaction(context.Background(), tx, blockHash))
+	assert.NotNil(t, client.VerifyTransaction(context.Background(), other, otherHash))
+	assert.Nil(t, client.VerifyTxHash(context.Background(), types.HashTransaction(other), otherHash))
+	assert.NotNil(t, client.VerifyTxHash(context.Background(), util.RandomHash(), blockHash))
+
+	confirmations, err := client.Confirmations(blockHash)
+	require.Nil(t, err)
+	assert.Equal(t, 2, confirmations)
+}
@@ -0,0 +1,35 @@
+package light
+
+import (
+	"bytes"
+	"github.com/cmkqwerty/blocker/crypto"
+)
+
+// Schedule decides which validator is allowed to sign the header at a given height.
+type Schedule interface {
+	IsScheduled(height int, publicKey []byte) bool
+}
+
+// RoundRobinSchedule expects the validators to take turns, one block each.
+type RoundRobinSchedule struct {
+	validators [][]byte
+}
+
+func NewRoundRobinSchedule(validators ...*crypto.PublicKey) *RoundRobinSchedule {
+	schedule := &RoundRobinSchedule{
+		validators: make([][]byte, len(validators)),
+	}
+	for i, validator := range validators {
+		schedule.validators[i] = validator.Bytes()
+	}
+
+	return schedule
+}
+
+func (s *RoundRobinSchedule) IsScheduled(height int, publicKey []byte) bool {
+	if len(s.validators) == 0 {
+		return false
+	}
+
+	return bytes.Equal(s.validators[height%len(s.validators)], publicKey)
+}
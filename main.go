@@ -2,25 +2,78 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
 	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/light"
 	"github.com/cmkqwerty/blocker/node"
 	"github.com/cmkqwerty/blocker/proto"
 	"github.com/cmkqwerty/blocker/util"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"log"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
+var (
+	mode      = flag.String("mode", "demo", "run mode: demo or light")
+	nodeAddr  = flag.String("node", "localhost:3000", "node the light client follows")
+	nodeID    = flag.String("node-id", "", "light mode: hex node key the followed node must present")
+	watch     = flag.String("watch", "", "light mode: comma separated addresses to watch")
+	genesis   = flag.String("genesis", "", "light mode: hex hash of the genesis block of the chain to follow")
+	schedule  = flag.String("validators", "", "light mode: comma separated hex public keys of the validators, in the order they take turns")
+	txHash    = flag.String("tx", "", "light mode: hex hash of a transaction to verify")
+	blockHash = flag.String("block", "", "light mode: hex hash of the block containing the transaction")
+
+	httpAddr    = flag.String("http", "", "demo mode: address of the HTTP gateway of the first node")
+	rpcAddr     = flag.String("rpc", "", "demo mode: address of the JSON-RPC server of the first node")
+	txIndex     = flag.Bool("txindex", false, "demo mode: maintain the transaction index")
+	metricsAddr = flag.String("metrics", "", "demo mode: address of the Prometheus metrics of the first node")
+	traceExport = flag.String("trace", "", "demo mode: export traces to stdout or otlp, disabled when empty")
+	adminAddr   = flag.String("admin", "", "demo mode: loopback address of the admin service of the first node")
+	logLevel    = flag.String("log-level", "info", "demo mode: initial log level of the nodes")
+	banDir      = flag.String("ban-dir", "", "demo mode: directory to persist the ban list of each node in")
+	addrDir     = flag.String("addrbook-dir", "", "demo mode: directory to persist the address book of each node in")
+	nodeKeyDir  = flag.String("node-key-dir", "", "demo mode: directory to persist the node key of each node in")
+
+	validatorKey   = flag.String("validator-key", "", "keystore file holding the validator private key")
+	passphraseFile = flag.String("passphrase-file", "", "file containing the passphrase of the validator key")
+)
+
 func main() {
+	flag.Parse()
+
+	switch *mode {
+	case "demo":
+		runDemo()
+	case "light":
+		runLightClient()
+	default:
+		log.Fatalf("unknown mode %q", *mode)
+	}
+}
+
+func runDemo() {
 	rand.Seed(time.Now().UnixNano())
 	validatorIndex := rand.Intn(3)
 
-	makeNode("localhost:3000", []string{}, validatorIndex == 0)
+	makeNode(node.ServerConfig{
+		ListenAddr:        "localhost:3000",
+		HTTPListenAddr:    *httpAddr,
+		JSONRPCListenAddr: *rpcAddr,
+		MetricsListenAddr: *metricsAddr,
+		AdminListenAddr:   *adminAddr,
+	}, []string{}, validatorIndex == 0)
 	time.Sleep(time.Second)
-	makeNode("localhost:3001", []string{"localhost:3000"}, validatorIndex == 1)
+	makeNode(node.ServerConfig{ListenAddr: "localhost:3001"}, []string{"localhost:3000"}, validatorIndex == 1)
 	time.Sleep(time.Second)
-	makeNode("localhost:3002", []string{"localhost:3001"}, validatorIndex == 2)
+	makeNode(node.ServerConfig{ListenAddr: "localhost:3002"}, []string{"localhost:3001"}, validatorIndex == 2)
 
 	for {
 		time.Sleep(time.Second)
@@ -28,26 +81,142 @@ func main() {
 	}
 }
 
-func makeNode(listenAddr string, bootstrapNodes []string, isValidator bool) *node.Node {
-	cfg := node.ServerConfig{
-		Version:    "0.0.1",
-		ListenAddr: listenAddr,
+func runLightClient() {
+	if *genesis == "" || *schedule == "" {
+		log.Fatal("light mode requires -genesis and -validators")
+	}
+
+	genesisHash, err := hex.DecodeString(*genesis)
+	if err != nil || len(genesisHash) != sha256.Size {
+		log.Fatalf("invalid genesis hash %q", *genesis)
+	}
+
+	var validators []*crypto.PublicKey
+	for _, s := range strings.Split(*schedule, ",") {
+		key, err := hex.DecodeString(strings.TrimSpace(s))
+		if err != nil || len(key) != crypto.PublicKeyLen {
+			log.Fatalf("invalid validator key %q", s)
+		}
+		validators = append(validators, crypto.PublicKeyFromBytes(key))
+	}
+
+	cfg := light.Config{
+		GenesisHash: genesisHash,
+		Schedule:    light.NewRoundRobinSchedule(validators...),
+		NodeID:      *nodeID,
+	}
+	if *watch != "" {
+		for _, s := range strings.Split(*watch, ",") {
+			address, err := crypto.ParseAddress(strings.TrimSpace(s))
+			if err != nil {
+				log.Fatal(err)
+			}
+			cfg.Addresses = append(cfg.Addresses, address)
+		}
+	}
+
+	client, err := light.Dial(*nodeAddr, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := client.Sync(ctx); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("synced headers up to height %d", client.Height())
+
+	if *txHash != "" {
+		tx, err := hex.DecodeString(*txHash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		block, err := hex.DecodeString(*blockHash)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := client.VerifyTxHash(ctx, tx, block); err != nil {
+			log.Fatal(err)
+		}
+		confirmations, _ := client.Confirmations(block)
+		log.Printf("transaction %s included with %d confirmations", *txHash, confirmations)
+		return
 	}
+
+	for {
+		time.Sleep(5 * time.Second)
+		if err := client.Sync(ctx); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("synced headers up to height %d", client.Height())
+	}
+}
+
+func makeNode(cfg node.ServerConfig, bootstrapNodes []string, isValidator bool) *node.Node {
+	cfg.Version = "0.0.1"
+	cfg.TxIndex = *txIndex
+
+	level, err := zapcore.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.LogLevel = level
+
+	if *banDir != "" {
+		cfg.BanListPath = filepath.Join(*banDir, strings.ReplaceAll(cfg.ListenAddr, ":", "_")+".bans.json")
+	}
+	if *addrDir != "" {
+		cfg.AddrBookPath = filepath.Join(*addrDir, strings.ReplaceAll(cfg.ListenAddr, ":", "_")+".addrs.json")
+	}
+	if *nodeKeyDir != "" {
+		key, err := node.LoadNodeKey(filepath.Join(*nodeKeyDir, strings.ReplaceAll(cfg.ListenAddr, ":", "_")+".nodekey"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.NodeKey = key
+	}
+
+	if *traceExport != "" {
+		tp, err := node.NewTracerProvider(*traceExport, cfg.ListenAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.TracerProvider = tp
+	}
+
 	if isValidator {
-		privKey := crypto.GeneratePrivateKey()
+		privKey, err := loadValidatorKey()
+		if err != nil {
+			log.Fatal(err)
+		}
 		cfg.PrivateKey = privKey
 	}
 
 	n := node.NewNode(cfg)
 	go func() {
-		log.Fatal(n.Start(listenAddr, bootstrapNodes))
+		log.Fatal(n.Start(cfg.ListenAddr, bootstrapNodes))
 	}()
 
 	return n
 }
 
+func loadValidatorKey() (*crypto.PrivateKey, error) {
+	if *validatorKey == "" {
+		return crypto.GeneratePrivateKey(), nil
+	}
+
+	passphrase, err := os.ReadFile(*passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.LoadKeyFile(*validatorKey, strings.TrimSpace(string(passphrase)))
+}
+
 func makeTransaction() {
-	client, err := grpc.Dial("localhost:3000", grpc.WithInsecure())
+	tlsConfig := crypto.NewTLSConfig(nil, false, func(*crypto.PublicKey) error { return nil })
+	client, err := grpc.Dial("localhost:3000", grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -74,6 +243,6 @@ func makeTransaction() {
 
 	_, err = c.HandleTransaction(context.TODO(), tx)
 	if err != nil {
-		log.Fatal(err)
+		log.Println("transaction rejected:", err)
 	}
 }
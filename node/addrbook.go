@@ -0,0 +1,312 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxKnownAddrs bounds the address book, the worst addresses make room
+	// for new ones.
+	maxKnownAddrs = 1000
+	// maxAddrFailures failed dials in a row make an address be forgotten.
+	maxAddrFailures = 10
+)
+
+// KnownAddr is a listen address of another node with what we know of it.
+type KnownAddr struct {
+	Addr string `json:"addr"`
+	// LastSeen is when we last talked to the node, zero when we never did.
+	LastSeen time.Time `json:"lastSeen,omitempty"`
+	// LastTried is when we last dialed the node.
+	LastTried time.Time `json:"lastTried,omitempty"`
+	// Failures counts the failed dials since the last success.
+	Failures int `json:"failures,omitempty"`
+}
+
+// better orders addresses from the most to the least promising.
+func (a *KnownAddr) better(b *KnownAddr) bool {
+	if a.Failures != b.Failures {
+		return a.Failures < b.Failures
+	}
+
+	return a.LastSeen.After(b.LastSeen)
+}
+
+// retryable reports whether enough time passed since the last failed dial,
+// which backs off like reconnects do.
+func (a *KnownAddr) retryable(now time.Time) bool {
+	return a.Failures == 0 || now.Sub(a.LastTried) >= reconnectDelay(a.Failures-1)
+}
+
+// AddrBook remembers the nodes we know of. With a path it is kept there as
+// JSON by Save, so they survive restarts.
+type AddrBook struct {
+	lock  sync.Mutex
+	path  string
+	addrs map[string]*KnownAddr
+	dirty bool
+}
+
+// NewAddrBook loads the addresses persisted at path, if any. An empty path
+// keeps them in memory only.
+func NewAddrBook(path string) (*AddrBook, error) {
+	b := &AddrBook{
+		path:  path,
+		addrs: make(map[string]*KnownAddr),
+	}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var known []*KnownAddr
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+	for _, addr := range known {
+		b.addrs[addr.Addr] = addr
+	}
+
+	return b, nil
+}
+
+// Add learns the addresses, ignoring malformed and known ones. It returns how
+// many were new.
+func (b *AddrBook) Add(addrs ...string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	added := 0
+	for _, addr := range addrs {
+		if _, ok := b.addrs[addr]; ok {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			continue
+		}
+		if len(b.addrs) >= maxKnownAddrs && !b.evict() {
+			break
+		}
+
+		b.addrs[addr] = &KnownAddr{Addr: addr}
+		b.dirty = true
+		added++
+	}
+
+	return added
+}
+
+// evict forgets the worst address we never talked to. The lock must be held.
+func (b *AddrBook) evict() bool {
+	var worst *KnownAddr
+	for _, addr := range b.addrs {
+		if !addr.LastSeen.IsZero() {
+			continue
+		}
+		if worst == nil || worst.better(addr) {
+			worst = addr
+		}
+	}
+	if worst == nil {
+		return false
+	}
+
+	delete(b.addrs, worst.Addr)
+
+	return true
+}
+
+// MarkGood records that we talked to the node.
+func (b *AddrBook) MarkGood(addr string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		known = &KnownAddr{Addr: addr}
+		b.addrs[addr] = known
+	}
+	known.LastSeen = time.Now().UTC()
+	known.Failures = 0
+	b.dirty = true
+}
+
+// MarkFailed records a failed dial. Addresses failing maxAddrFailures times in
+// a row are forgotten.
+func (b *AddrBook) MarkFailed(addr string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		return
+	}
+	known.LastTried = time.Now().UTC()
+	known.Failures++
+	if known.Failures >= maxAddrFailures {
+		delete(b.addrs, addr)
+	}
+	b.dirty = true
+}
+
+func (b *AddrBook) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return len(b.addrs)
+}
+
+// Get returns what we know of the address.
+func (b *AddrBook) Get(addr string) (KnownAddr, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		return KnownAddr{}, false
+	}
+
+	return *known, true
+}
+
+// snapshot copies the addresses, best first. The lock must be held.
+func (b *AddrBook) snapshot() []KnownAddr {
+	known := make([]KnownAddr, 0, len(b.addrs))
+	for _, addr := range b.addrs {
+		known = append(known, *addr)
+	}
+	sort.Slice(known, func(i, j int) bool {
+		return known[i].better(&known[j])
+	})
+
+	return known
+}
+
+// Addrs returns up to max of the best addresses, to share with other nodes.
+func (b *AddrBook) Addrs(max int) []string {
+	b.lock.Lock()
+	known := b.snapshot()
+	b.lock.Unlock()
+
+	addrs := make([]string, 0, max)
+	for _, addr := range known {
+		if len(addrs) == max {
+			break
+		}
+		addrs = append(addrs, addr.Addr)
+	}
+
+	return addrs
+}
+
+// Select picks up to count addresses to dial among those ok accepts. It
+// prefers subnets not in groups, which counts the subnets we are connected
+// to, so that a single network cannot surround us with its nodes.
+func (b *AddrBook) Select(count int, groups map[string]int, ok func(addr string) bool) []string {
+	b.lock.Lock()
+	known := b.snapshot()
+	b.lock.Unlock()
+
+	// addresses of the same quality are tried in random order
+	rand.Shuffle(len(known), func(i, j int) {
+		known[i], known[j] = known[j], known[i]
+	})
+	sort.SliceStable(known, func(i, j int) bool {
+		return known[i].Failures < known[j].Failures
+	})
+
+	var (
+		now      = time.Now()
+		used     = make(map[string]int, len(groups))
+		selected []string
+		rest     []string
+	)
+	for group, n := range groups {
+		used[group] = n
+	}
+
+	for _, addr := range known {
+		if len(selected) == count {
+			break
+		}
+		if !addr.retryable(now) || !ok(addr.Addr) {
+			continue
+		}
+
+		group := addrGroup(addr.Addr)
+		if used[group] > 0 {
+			rest = append(rest, addr.Addr)
+			continue
+		}
+		used[group]++
+		selected = append(selected, addr.Addr)
+	}
+
+	for _, addr := range rest {
+		if len(selected) == count {
+			break
+		}
+		selected = append(selected, addr)
+	}
+
+	return selected
+}
+
+// Save persists the addresses when they changed since the last save.
+func (b *AddrBook) Save() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.path == "" || !b.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return err
+	}
+	b.dirty = false
+
+	return nil
+}
+
+// addrGroup returns the subnet of the address nodes are spread across: the
+// /16 of IPv4 and the /32 of IPv6 addresses. Host names that do not resolve
+// are their own group.
+func addrGroup(addr string) string {
+	ips := hostIPs(addr)
+	if len(ips) == 0 {
+		host, _, _ := net.SplitHostPort(addr)
+		return host
+	}
+
+	ip := net.ParseIP(ips[0])
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(32, 128)).String()
+}
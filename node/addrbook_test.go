@@ -0,0 +1,113 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddrBook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addrs.json")
+
+	book, err := NewAddrBook(path)
+	require.Nil(t, err)
+	assert.Equal(t, 2, book.Add("10.0.0.1:3000", "10.0.0.2:3000", "10.0.0.1:3000", "malformed"))
+	book.MarkGood("10.0.0.1:3000")
+	book.MarkFailed("10.0.0.2:3000")
+	require.Nil(t, book.Save())
+
+	// addresses survive a restart
+	book, err = NewAddrBook(path)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3000"}, book.Addrs(10))
+	known, ok := book.Get("10.0.0.2:3000")
+	require.True(t, ok)
+	assert.Equal(t, 1, known.Failures)
+	known, ok = book.Get("10.0.0.1:3000")
+	require.True(t, ok)
+	assert.False(t, known.LastSeen.IsZero())
+
+	for i := 1; i < maxAddrFailures; i++ {
+		book.MarkFailed("10.0.0.2:3000")
+	}
+	_, ok = book.Get("10.0.0.2:3000")
+	assert.False(t, ok)
+	assert.Equal(t, 1, book.Len())
+}
+
+func TestAddrBookSelect(t *testing.T) {
+	book, err := NewAddrBook("")
+	require.Nil(t, err)
+	book.Add(
+		"10.1.0.1:3000", "10.1.0.2:3000", "10.1.0.3:3000",
+		"10.2.0.1:3000", "10.2.0.2:3000",
+		"10.3.0.1:3000",
+	)
+	all := func(string) bool { return true }
+
+	selected := book.Select(3, nil, all)
+	require.Len(t, selected, 3)
+	groups := make(map[string]int)
+	for _, addr := range selected {
+		groups[addrGroup(addr)]++
+	}
+	assert.Len(t, groups, 3)
+
+	// subnets we are connected to come last
+	selected = book.Select(2, map[string]int{"10.1.0.0": 1, "10.2.0.0": 1}, all)
+	require.Len(t, selected, 2)
+	assert.Equal(t, "10.3.0.1:3000", selected[0])
+
+	selected = book.Select(10, nil, func(addr string) bool { return addr != "10.3.0.1:3000" })
+	assert.Len(t, selected, 5)
+	assert.NotContains(t, selected, "10.3.0.1:3000")
+}
+
+func TestInboundLimit(t *testing.T) {
+	var (
+		n     = NewNode(ServerConfig{MaxInbound: 1})
+		addr  = serveNode(t, n)
+		first = NewNode(ServerConfig{})
+		extra = NewNode(ServerConfig{})
+	)
+	first.ListenAddr = serveNode(t, first)
+	extra.ListenAddr = serveNode(t, extra)
+
+	p, v, err := first.dialRemoteNode(addr)
+	require.Nil(t, err)
+	first.addPeer(p, v)
+	assert.True(t, p.outbound)
+	assert.Equal(t, 1, n.countPeers(false))
+
+	_, _, err = extra.dialRemoteNode(addr)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 1, n.countPeers(false))
+	// the refused node may still be dialed later on
+	_, ok := n.addrBook.Get(extra.ListenAddr)
+	assert.True(t, ok)
+}
+
+func TestExchangeAddrs(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{})
+		client = NewNode(ServerConfig{})
+	)
+	n.learnAddrs("10.0.0.1:3000", "10.0.0.2:3000")
+	connect(t, client, serveNode(t, n))
+
+	client.exchangeAddrs()
+
+	for _, addr := range []string{"10.0.0.1:3000", "10.0.0.2:3000"} {
+		_, ok := client.addrBook.Get(addr)
+		assert.True(t, ok, addr)
+	}
+
+	resp, err := n.GetAddrs(context.Background(), &proto.GetAddrsRequest{Max: 1})
+	require.Nil(t, err)
+	assert.Len(t, resp.Addrs, 1)
+}
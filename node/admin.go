@@ -0,0 +1,131 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/cmkqwerty/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"time"
+)
+
+// AdminServer serves the Admin service, which changes the node at runtime.
+type AdminServer struct {
+	node *Node
+	proto.UnimplementedAdminServer
+}
+
+func NewAdminServer(node *Node) *AdminServer {
+	return &AdminServer{node: node}
+}
+
+func (a *AdminServer) GetLogLevels(ctx context.Context, req *proto.LogLevelsRequest) (*proto.LogLevels, error) {
+	return &proto.LogLevels{Levels: a.node.LogLevels()}, nil
+}
+
+func (a *AdminServer) SetLogLevel(ctx context.Context, req *proto.SetLogLevelRequest) (*proto.LogLevels, error) {
+	if err := a.node.SetLogLevel(req.Subsystem, req.Level); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &proto.LogLevels{Levels: a.node.LogLevels()}, nil
+}
+
+func (a *AdminServer) GetPeerScores(ctx context.Context, req *proto.PeerScoresRequest) (*proto.PeerScores, error) {
+	return &proto.PeerScores{Scores: a.node.scores.all()}, nil
+}
+
+func (a *AdminServer) ListBans(ctx context.Context, req *proto.ListBansRequest) (*proto.BanList, error) {
+	return a.banList(), nil
+}
+
+// Ban bans the node ID or IP and disconnects the matching peers.
+func (a *AdminServer) Ban(ctx context.Context, req *proto.BanRequest) (*proto.BanList, error) {
+	d := a.node.banDuration()
+	if req.DurationSeconds > 0 {
+		d = time.Duration(req.DurationSeconds) * time.Second
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "banned by admin"
+	}
+
+	var err error
+	if req.NodeId != "" {
+		err = a.node.banNode(req.NodeId, d, reason)
+	} else {
+		err = a.node.banIP(req.Ip, d, reason)
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return a.banList(), nil
+}
+
+func (a *AdminServer) Unban(ctx context.Context, req *proto.UnbanRequest) (*proto.BanList, error) {
+	key := req.Ip
+	if req.NodeId != "" {
+		key = req.NodeId
+	}
+	if err := a.node.bans.Unban(key); err != nil {
+		return nil, err
+	}
+
+	return a.banList(), nil
+}
+
+func (a *AdminServer) banList() *proto.BanList {
+	list := &proto.BanList{}
+	for _, entry := range a.node.bans.List() {
+		list.Bans = append(list.Bans, &proto.Ban{
+			Ip:     entry.IP,
+			NodeId: entry.NodeID,
+			Until:  entry.Until.Unix(),
+			Reason: entry.Reason,
+		})
+	}
+
+	return list
+}
+
+// isLoopbackAddr reports whether the address only listens on the loopback
+// interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveAdmin serves the Admin service on its own listener, apart from the
+// services open to peers. The service is unauthenticated, so it only listens
+// on loopback addresses.
+func (n *Node) serveAdmin(listenAddr string) error {
+	if !isLoopbackAddr(listenAddr) {
+		return fmt.Errorf("admin service must listen on a loopback address, not %s", listenAddr)
+	}
+
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return err
+	}
+
+	return n.adminServer().Serve(ln)
+}
+
+func (n *Node) adminServer() *grpc.Server {
+	server := grpc.NewServer(n.grpcServerOptions()...)
+	proto.RegisterAdminServer(server, NewAdminServer(n))
+
+	return server
+}
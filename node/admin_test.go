@@ -0,0 +1,44 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServeAdminOnLoopbackOnly(t *testing.T) {
+	n := NewNode(ServerConfig{})
+
+	for _, addr := range []string{":0", "0.0.0.0:0", "192.0.2.1:0", "example.com:0"} {
+		assert.ErrorContains(t, n.serveAdmin(addr), "loopback", addr)
+	}
+	assert.True(t, isLoopbackAddr("localhost:3100"))
+	assert.True(t, isLoopbackAddr("127.0.0.1:3100"))
+	assert.True(t, isLoopbackAddr("[::1]:3100"))
+}
+
+func TestAdminIgnoresBans(t *testing.T) {
+	n := NewNode(ServerConfig{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := n.adminServer()
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	admin := proto.NewAdminClient(conn)
+
+	// the operator lifts a ban of its own IP
+	require.Nil(t, n.bans.Ban("127.0.0.1", time.Hour, "test"))
+	list, err := admin.Unban(context.Background(), &proto.UnbanRequest{Ip: "127.0.0.1"})
+	require.Nil(t, err)
+	assert.Empty(t, list.Bans)
+}
@@ -0,0 +1,166 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"io/fs"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BanEntry bans either an IP or a node ID.
+type BanEntry struct {
+	IP     string    `json:"ip,omitempty"`
+	NodeID string    `json:"nodeId,omitempty"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// key is the IP or node ID the entry bans.
+func (e BanEntry) key() string {
+	if e.NodeID != "" {
+		return e.NodeID
+	}
+
+	return e.IP
+}
+
+// BanList holds the banned IPs and node IDs. With a path it is kept there as
+// JSON, so bans survive restarts.
+type BanList struct {
+	lock sync.Mutex
+	path string
+	bans map[string]BanEntry
+}
+
+// NewBanList loads the bans persisted at path, if any. An empty path keeps the
+// bans in memory only.
+func NewBanList(path string) (*BanList, error) {
+	b := &BanList{
+		path: path,
+		bans: make(map[string]BanEntry),
+	}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []BanEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		b.bans[entry.key()] = entry
+	}
+
+	return b, nil
+}
+
+func (b *BanList) Ban(ip string, d time.Duration, reason string) error {
+	if net.ParseIP(ip) == nil {
+		return &net.ParseError{Type: "IP address", Text: ip}
+	}
+
+	return b.add(BanEntry{IP: ip, Until: time.Now().Add(d).UTC(), Reason: reason})
+}
+
+func (b *BanList) BanNode(id string, d time.Duration, reason string) error {
+	if key, err := hex.DecodeString(id); err != nil || len(key) != crypto.PublicKeyLen {
+		return fmt.Errorf("invalid node ID [%s]", id)
+	}
+
+	return b.add(BanEntry{NodeID: id, Until: time.Now().Add(d).UTC(), Reason: reason})
+}
+
+func (b *BanList) add(entry BanEntry) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.bans[entry.key()] = entry
+
+	return b.save()
+}
+
+// Unban lifts the ban of an IP or node ID.
+func (b *BanList) Unban(key string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.bans, key)
+
+	return b.save()
+}
+
+// IsBanned reports whether the IP or node ID is banned.
+func (b *BanList) IsBanned(key string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entry, ok := b.bans[key]
+
+	return ok && time.Now().Before(entry.Until)
+}
+
+// List returns the bans in effect, ordered by IP or node ID.
+func (b *BanList) List() []BanEntry {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	entries := make([]BanEntry, 0, len(b.bans))
+	for _, entry := range b.bans {
+		if now.Before(entry.Until) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key() < entries[j].key()
+	})
+
+	return entries
+}
+
+// save writes the bans in effect, dropping the expired ones. The lock must be
+// held.
+func (b *BanList) save() error {
+	now := time.Now()
+	entries := make([]BanEntry, 0, len(b.bans))
+	for key, entry := range b.bans {
+		if !now.Before(entry.Until) {
+			delete(b.bans, key)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if b.path == "" {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key() < entries[j].key()
+	})
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, b.path)
+}
@@ -3,28 +3,58 @@ package node
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/cmkqwerty/blocker/crypto"
 	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/script"
 	"github.com/cmkqwerty/blocker/types"
+	"sync"
+	"time"
 )
 
 const godSeed = "d12cda4733e2e24377cc161b55bf447a13a615d48838b33ab7634b77531734dc"
 
+// maxBlockTimeDrift is how far ahead of our clock the timestamp of a block
+// may be.
+const maxBlockTimeDrift = time.Minute
+
+var (
+	ErrTxNotFinal      = errors.New("transaction is not final")
+	ErrLockTooFar      = errors.New("transaction is locked too far ahead")
+	ErrTxIndexDisabled = errors.New("transaction index is disabled")
+
+	// Reasons ValidateTransaction rejects a transaction for.
+	ErrNoInputs          = errors.New("no inputs")
+	ErrDoubleSpend       = errors.New("double spend")
+	ErrMissingInput      = errors.New("missing input")
+	ErrInvalidInput      = errors.New("invalid input")
+	ErrInvalidIssuance   = errors.New("invalid issuance")
+	ErrInvalidOutput     = errors.New("invalid output")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
 type HeaderList struct {
+	lock    sync.RWMutex
 	headers []*proto.Header
 }
 
 func NewHeaderList() *HeaderList {
-	return &HeaderList{[]*proto.Header{}}
+	return &HeaderList{headers: []*proto.Header{}}
 }
 
 func (h *HeaderList) Add(header *proto.Header) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
 	h.headers = append(h.headers, header)
 }
 
 func (h *HeaderList) Get(index int) *proto.Header {
-	if index > h.Height() {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if index < 0 || index >= len(h.headers) {
 		panic("index out of range")
 	}
 
@@ -36,6 +66,9 @@ func (h *HeaderList) Height() int {
 }
 
 func (h *HeaderList) Len() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
 	return len(h.headers)
 }
 
@@ -43,22 +76,36 @@ type UTXO struct {
 	Hash     string
 	OutIndex int
 	Amount   int64
-	Spent    bool
+	Address  []byte
+	// AssetID is the hex encoded asset of the amount, empty for the native
+	// coin.
+	AssetID string
+	// Height and Time of the block that created the output, used for
+	// relative timelocks.
+	Height int
+	Time   int64
 }
 
 type Chain struct {
-	txStore    TXStorer
-	blockStore BlockStorer
-	utxoStore  UTXOStorer
-	headers    *HeaderList
+	txStore     TXStorer
+	blockStore  BlockStorer
+	utxoStore   UTXOStorer
+	anchorStore AnchorStorer
+	// txIndex is optional, nil unless enabled with EnableTxIndex.
+	txIndex TxIndexer
+	headers *HeaderList
+	// addLock serializes adding blocks, which may arrive from several peers
+	// at once.
+	addLock sync.Mutex
 }
 
 func NewChain(blockStore BlockStorer, txStore TXStorer) *Chain {
 	chain := &Chain{
-		blockStore: blockStore,
-		txStore:    txStore,
-		utxoStore:  NewMemoryUTXOStore(),
-		headers:    NewHeaderList(),
+		blockStore:  blockStore,
+		txStore:     txStore,
+		utxoStore:   NewMemoryUTXOStore(),
+		anchorStore: NewMemoryAnchorStore(),
+		headers:     NewHeaderList(),
 	}
 
 	err := chain.addBlock(createGenesisBlock())
@@ -74,6 +121,9 @@ func (c *Chain) Height() int {
 }
 
 func (c *Chain) AddBlock(block *proto.Block) error {
+	c.addLock.Lock()
+	defer c.addLock.Unlock()
+
 	if err := c.ValidateBlock(block); err != nil {
 		return err
 	}
@@ -82,20 +132,43 @@ func (c *Chain) AddBlock(block *proto.Block) error {
 }
 
 func (c *Chain) addBlock(block *proto.Block) error {
-	c.headers.Add(block.Header)
+	var (
+		height    = c.headers.Len()
+		blockHash = types.HashBlock(block)
+	)
 
 	for _, tx := range block.Transactions {
 		if err := c.txStore.Put(tx); err != nil {
 			return err
 		}
-		hash := hex.EncodeToString(types.HashTransaction(tx))
+		txHash := types.HashTransaction(tx)
+		hash := hex.EncodeToString(txHash)
 
 		for it, output := range tx.Outputs {
+			// data outputs can never be spent, so they are indexed by their
+			// payload instead of being added to the utxo set
+			if types.IsDataOutput(output) {
+				if err := c.addAnchor(&proto.Anchor{
+					PayloadHash: types.HashData(output.Data),
+					BlockHash:   blockHash,
+					Height:      int32(height),
+					Timestamp:   block.Header.Timestamp,
+					TxHash:      txHash,
+					OutIndex:    uint32(it),
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+
 			utxo := &UTXO{
 				Hash:     hash,
 				Amount:   output.Amount,
 				OutIndex: it,
-				Spent:    false,
+				Address:  output.Address,
+				AssetID:  hex.EncodeToString(output.AssetId),
+				Height:   height,
+				Time:     block.Header.Timestamp / int64(time.Second),
 			}
 
 			if err := c.utxoStore.Put(utxo); err != nil {
@@ -104,20 +177,182 @@ func (c *Chain) addBlock(block *proto.Block) error {
 		}
 
 		for _, input := range tx.Inputs {
-			key := fmt.Sprintf("%s_%d", hex.EncodeToString(input.PrevTxHash), input.PrevOutIndex)
-			utxo, err := c.utxoStore.Get(key)
-			if err != nil {
+			if err := c.utxoStore.Delete(utxoKey(input.PrevTxHash, input.PrevOutIndex)); err != nil {
 				return err
 			}
+		}
+	}
 
-			utxo.Spent = true
-			if err := c.utxoStore.Put(utxo); err != nil {
+	if err := c.blockStore.Put(block); err != nil {
+		return err
+	}
+
+	if c.txIndex != nil {
+		if err := c.indexBlock(block, height); err != nil {
+			return err
+		}
+	}
+
+	c.headers.Add(block.Header)
+
+	return nil
+}
+
+// EnableTxIndex makes the chain maintain the transaction index, which is
+// first rebuilt from the blocks added so far.
+func (c *Chain) EnableTxIndex(index TxIndexer) error {
+	c.txIndex = index
+
+	return c.RebuildTxIndex()
+}
+
+// RebuildTxIndex drops the transaction index and indexes all blocks again.
+func (c *Chain) RebuildTxIndex() error {
+	if c.txIndex == nil {
+		return ErrTxIndexDisabled
+	}
+
+	if err := c.txIndex.Reset(); err != nil {
+		return err
+	}
+
+	for height := 0; height <= c.Height(); height++ {
+		block, err := c.GetBlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		if err := c.indexBlock(block, height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexBlock records the location of the transactions of the block and the
+// addresses they pay to or spend from.
+func (c *Chain) indexBlock(block *proto.Block, height int) error {
+	blockHash := types.HashBlock(block)
+
+	for i, tx := range block.Transactions {
+		var addresses [][]byte
+		for _, input := range tx.Inputs {
+			output, err := c.getOutput(input)
+			if err != nil {
 				return err
 			}
+			if len(output.Address) > 0 {
+				addresses = append(addresses, output.Address)
+			}
+		}
+		for _, output := range tx.Outputs {
+			if len(output.Address) > 0 {
+				addresses = append(addresses, output.Address)
+			}
+		}
+
+		location := &TxLocation{
+			BlockHash: blockHash,
+			Height:    height,
+			Index:     i,
+		}
+		if err := c.txIndex.Put(hex.EncodeToString(types.HashTransaction(tx)), location, addresses); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetTxLocation returns the block that included the transaction. It requires
+// the transaction index.
+func (c *Chain) GetTxLocation(hash []byte) (*TxLocation, error) {
+	if c.txIndex == nil {
+		return nil, ErrTxIndexDisabled
+	}
+
+	return c.txIndex.Get(hex.EncodeToString(hash))
+}
+
+// Confirmations returns the number of blocks on top of and including the
+// location.
+func (c *Chain) Confirmations(location *TxLocation) int {
+	return c.Height() - location.Height + 1
+}
+
+// AddressHistory returns the hashes of the transactions paying to or
+// spending from the address, oldest first. It requires the transaction index.
+func (c *Chain) AddressHistory(address []byte) ([][]byte, error) {
+	if c.txIndex == nil {
+		return nil, ErrTxIndexDisabled
+	}
+
+	history, err := c.txIndex.History(address)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, len(history))
+	for i, hash := range history {
+		if hashes[i], err = hex.DecodeString(hash); err != nil {
+			return nil, err
+		}
+	}
+
+	return hashes, nil
+}
+
+// addAnchor stores the anchor unless the payload was anchored before, so
+// lookups return the earliest proof of existence.
+func (c *Chain) addAnchor(anchor *proto.Anchor) error {
+	if _, err := c.anchorStore.Get(hex.EncodeToString(anchor.PayloadHash)); err == nil {
+		return nil
+	}
+
+	return c.anchorStore.Put(anchor)
+}
+
+// GetAnchor returns the first block that anchored a payload with the given
+// sha256 hash in a data output.
+func (c *Chain) GetAnchor(payloadHash []byte) (*proto.Anchor, error) {
+	return c.anchorStore.Get(hex.EncodeToString(payloadHash))
+}
+
+// GetUTXO returns the unspent output of the transaction at the index.
+func (c *Chain) GetUTXO(txHash []byte, index uint32) (*UTXO, error) {
+	return c.utxoStore.Get(utxoKey(txHash, index))
+}
+
+// ListUnspent returns the unspent outputs owned by the address, of the
+// native coin and of any asset.
+func (c *Chain) ListUnspent(address []byte) ([]*UTXO, error) {
+	return c.utxoStore.ListByAddress(address)
+}
+
+// Balance returns the unspent native coins owned by the address.
+func (c *Chain) Balance(address []byte) (int64, error) {
+	return c.AssetBalance(address, nil)
+}
+
+// AssetBalance returns the unspent amount of the asset owned by the address.
+// An empty asset ID selects the native coin.
+func (c *Chain) AssetBalance(address []byte, assetID []byte) (int64, error) {
+	utxos, err := c.ListUnspent(address)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		asset   = hex.EncodeToString(assetID)
+		balance int64
+	)
+	for _, utxo := range utxos {
+		if utxo.AssetID == asset {
+			balance += utxo.Amount
 		}
 	}
 
-	return c.blockStore.Put(block)
+	return balance, nil
 }
 
 func (c *Chain) GetBlockByHash(hash []byte) (*proto.Block, error) {
@@ -127,6 +362,9 @@ func (c *Chain) GetBlockByHash(hash []byte) (*proto.Block, error) {
 }
 
 func (c *Chain) GetBlockByHeight(height int) (*proto.Block, error) {
+	if height < 0 {
+		return nil, fmt.Errorf("invalid height (%d)", height)
+	}
 	if c.Height() < height {
 		return nil, fmt.Errorf("given height (%d) too high - current height (%d)", height, c.Height())
 	}
@@ -154,7 +392,27 @@ func (c *Chain) ValidateBlock(block *proto.Block) error {
 		return fmt.Errorf("prev block hash mismatch")
 	}
 
+	// validate height and timestamp against the tip
+	if int(block.Header.Height) != c.Height()+1 {
+		return fmt.Errorf("invalid block height (%d), expected %d", block.Header.Height, c.Height()+1)
+	}
+	if block.Header.Timestamp <= currentBlock.Header.Timestamp {
+		return fmt.Errorf("block timestamp is not after the previous block")
+	}
+	if block.Header.Timestamp > time.Now().Add(maxBlockTimeDrift).UnixNano() {
+		return fmt.Errorf("block timestamp is too far in the future")
+	}
+
+	spent := make(map[string]bool)
 	for _, tx := range block.Transactions {
+		for _, input := range tx.Inputs {
+			key := utxoKey(input.PrevTxHash, input.PrevOutIndex)
+			if spent[key] {
+				return fmt.Errorf("output %s is spent twice in block", key)
+			}
+			spent[key] = true
+		}
+
 		if err := c.ValidateTransaction(tx); err != nil {
 			return err
 		}
@@ -164,43 +422,191 @@ func (c *Chain) ValidateBlock(block *proto.Block) error {
 }
 
 func (c *Chain) ValidateTransaction(tx *proto.Transaction) error {
-	// verify signature
-	if !types.VerifyTransaction(tx) {
-		return fmt.Errorf("invalid transaction signature")
-	}
-
-	// check if inputs are not spent
+	// check if inputs are not spent and are signed by their owners
 	var (
-		nInputs   = len(tx.Inputs)
 		hash      = hex.EncodeToString(types.HashTransaction(tx))
-		sumInputs = 0
+		height    = int64(c.Height())
+		tipTime   = c.headers.Get(c.Height()).Timestamp / int64(time.Second)
+		spent     = make(map[string]bool)
+		sumInputs = make(map[string]int64)
+		final     = types.IsFinal(tx, height, tipTime)
+		ctx       = &script.Context{
+			SigHash:  types.HashTransactionForSigning(tx),
+			LockTime: tx.LockTime,
+		}
 	)
-	for i := 0; i < nInputs; i++ {
-		prevHash := hex.EncodeToString(tx.Inputs[i].PrevTxHash)
-		key := fmt.Sprintf("%s_%d", prevHash, i)
+	// only issuances may create value without spending any, anchoring data
+	// costs an output as anything else
+	if len(tx.Inputs) == 0 && tx.Issuance == nil {
+		return fmt.Errorf("%w: transaction %s", ErrNoInputs, hash)
+	}
+
+	for i, input := range tx.Inputs {
+		key := utxoKey(input.PrevTxHash, input.PrevOutIndex)
+		if spent[key] {
+			return fmt.Errorf("%w: input %d of transaction %s spends the same output twice", ErrDoubleSpend, i, hash)
+		}
+		spent[key] = true
+
 		utxo, err := c.utxoStore.Get(key)
+		if err != nil {
+			return fmt.Errorf("%w: input %d of transaction %s spends a missing or spent output", ErrMissingInput, i, hash)
+		}
+
+		output, err := c.getOutput(input)
 		if err != nil {
 			return err
 		}
 
-		sumInputs += int(utxo.Amount)
+		if err := verifyInput(ctx, input, output); err != nil {
+			return fmt.Errorf("%w: input %d of transaction %s: %w", ErrInvalidInput, i, hash, err)
+		}
+
+		if !types.IsSequenceFinal(input.Sequence, int64(utxo.Height), utxo.Time, height, tipTime) {
+			final = false
+		}
+
+		if sumInputs[utxo.AssetID], err = addAmount(sumInputs[utxo.AssetID], utxo.Amount); err != nil {
+			return fmt.Errorf("%w: inputs of transaction %s: %w", ErrInvalidInput, hash, err)
+		}
+	}
+
+	// the minted supply counts as an input of the new asset
+	if tx.Issuance != nil {
+		if err := types.ValidateIssuance(tx.Issuance); err != nil {
+			return fmt.Errorf("%w: transaction %s: %w", ErrInvalidIssuance, hash, err)
+		}
+		assetID, err := types.IssuedAssetID(tx)
+		if err != nil {
+			return fmt.Errorf("%w: transaction %s: %w", ErrInvalidIssuance, hash, err)
+		}
+		asset := hex.EncodeToString(assetID)
+		if sumInputs[asset], err = addAmount(sumInputs[asset], tx.Issuance.Amount); err != nil {
+			return fmt.Errorf("%w: transaction %s: %w", ErrInvalidIssuance, hash, err)
+		}
+	}
 
-		if utxo.Spent {
-			return fmt.Errorf("input %d of transaction %s is already spent", i, hash)
+	sumOutputs := make(map[string]int64)
+	for i, output := range tx.Outputs {
+		err := validateOutput(output)
+		if err == nil {
+			asset := hex.EncodeToString(output.AssetId)
+			sumOutputs[asset], err = addAmount(sumOutputs[asset], output.Amount)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: output %d of transaction %s: %w", ErrInvalidOutput, i, hash, err)
 		}
 	}
 
-	sumOutputs := 0
-	for _, output := range tx.Outputs {
-		sumOutputs += int(output.Amount)
+	// every asset is conserved on its own, any native coin left over is the
+	// fee
+	for assetID, sum := range sumOutputs {
+		if sumInputs[assetID] < sum {
+			if assetID == "" {
+				return fmt.Errorf("%w: transaction %s", ErrInsufficientFunds, hash)
+			}
+			return fmt.Errorf("%w: transaction %s lacks asset %s", ErrInsufficientFunds, hash, assetID)
+		}
 	}
-	if sumInputs < sumOutputs {
-		return fmt.Errorf("transaction %s has insufficient funds", hash)
+
+	// timelocks are checked last so a premature transaction is otherwise valid
+	if !final {
+		return fmt.Errorf("%w: transaction %s is timelocked", ErrTxNotFinal, hash)
 	}
 
 	return nil
 }
 
+// getOutput returns the output the input is spending.
+func (c *Chain) getOutput(input *proto.TxInput) (*proto.TxOutput, error) {
+	prevTx, err := c.txStore.Get(hex.EncodeToString(input.PrevTxHash))
+	if err != nil {
+		return nil, err
+	}
+
+	if int(input.PrevOutIndex) >= len(prevTx.Outputs) {
+		return nil, fmt.Errorf("transaction [%x] has no output %d", input.PrevTxHash, input.PrevOutIndex)
+	}
+
+	return prevTx.Outputs[input.PrevOutIndex], nil
+}
+
+func verifyInput(ctx *script.Context, input *proto.TxInput, output *proto.TxOutput) error {
+	if len(output.LockingScript) > 0 {
+		return script.Verify(input.UnlockingScript, output.LockingScript, ctx)
+	}
+
+	if output.Multisig != nil {
+		if !types.VerifyMultisigInput(ctx.SigHash, input, output.Multisig) {
+			return fmt.Errorf("invalid multisig signatures")
+		}
+
+		return nil
+	}
+
+	if !types.VerifyInput(ctx.SigHash, input) {
+		return fmt.Errorf("invalid transaction signature")
+	}
+
+	address := crypto.PublicKeyFromBytes(input.PublicKey).Address()
+	if !bytes.Equal(address.Bytes(), output.Address) {
+		return fmt.Errorf("public key does not own the spent output")
+	}
+
+	return nil
+}
+
+func validateOutput(output *proto.TxOutput) error {
+	if types.IsDataOutput(output) {
+		if len(output.AssetId) > 0 {
+			return fmt.Errorf("data output must not carry an asset")
+		}
+
+		return types.ValidateDataOutput(output)
+	}
+
+	if output.Amount <= 0 || output.Amount > types.MaxMoney {
+		return fmt.Errorf("invalid amount %d", output.Amount)
+	}
+
+	if err := types.ValidateAssetID(output.AssetId); err != nil {
+		return err
+	}
+
+	if len(output.LockingScript) > 0 {
+		if len(output.Address) > 0 || output.Multisig != nil {
+			return fmt.Errorf("script output must not have an address or multisig lock")
+		}
+
+		_, err := script.Parse(output.LockingScript)
+		return err
+	}
+
+	if output.Multisig != nil {
+		if len(output.Address) > 0 {
+			return fmt.Errorf("multisig output must not have an address")
+		}
+
+		return types.ValidateMultisigLock(output.Multisig)
+	}
+
+	return crypto.ValidateAddressBytes(output.Address)
+}
+
+// addAmount adds up amounts of an asset, failing past types.MaxMoney rather
+// than overflowing.
+func addAmount(sum int64, amount int64) (int64, error) {
+	if amount < 0 || amount > types.MaxMoney-sum {
+		return 0, fmt.Errorf("amounts exceed %d", int64(types.MaxMoney))
+	}
+
+	return sum + amount, nil
+}
+
+func utxoKey(hash []byte, index uint32) string {
+	return fmt.Sprintf("%s_%d", hex.EncodeToString(hash), index)
+}
+
 func createGenesisBlock() *proto.Block {
 	privateKey := crypto.NewPrivateKeyFromSeedString(godSeed)
 
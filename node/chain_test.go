@@ -1,13 +1,17 @@
 package node
 
 import (
+	"crypto/sha256"
 	"github.com/cmkqwerty/blocker/crypto"
 	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/script"
 	"github.com/cmkqwerty/blocker/types"
 	"github.com/cmkqwerty/blocker/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math"
 	"testing"
+	"time"
 )
 
 func randomBlock(t *testing.T, chain *Chain) *proto.Block {
@@ -16,6 +20,7 @@ func randomBlock(t *testing.T, chain *Chain) *proto.Block {
 	prevBlock, err := chain.GetBlockByHeight(chain.Height())
 	require.Nil(t, err)
 
+	block.Header.Height = prevBlock.Header.Height + 1
 	block.Header.PrevHash = types.HashBlock(prevBlock)
 	types.SignBlock(privKey, block)
 
@@ -41,6 +46,40 @@ func TestChainHeight(t *testing.T) {
 	}
 }
 
+func TestAddBlockHeightAndTimestamp(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	tip, err := chain.GetBlockByHeight(chain.Height())
+	require.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		height    int32
+		timestamp int64
+	}{
+		{"height of the tip", 1, time.Now().UnixNano()},
+		{"height skipped", 3, time.Now().UnixNano()},
+		{"same time as the tip", 2, tip.Header.Timestamp},
+		{"before the tip", 2, tip.Header.Timestamp - 1},
+		{"too far in the future", 2, time.Now().Add(2 * maxBlockTimeDrift).UnixNano()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			block := randomBlock(t, chain)
+			block.Header.Height = test.height
+			block.Header.Timestamp = test.timestamp
+			types.SignBlock(crypto.GeneratePrivateKey(), block)
+			assert.NotNil(t, chain.AddBlock(block))
+			assert.Equal(t, 1, chain.Height())
+		})
+	}
+
+	block := randomBlock(t, chain)
+	block.Header.Timestamp = time.Now().Add(maxBlockTimeDrift / 2).UnixNano()
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+}
+
 func TestAddBlock(t *testing.T) {
 	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
 
@@ -68,7 +107,7 @@ func TestAddBlockWithTX(t *testing.T) {
 		recipient  = crypto.GeneratePrivateKey().Public().Address().Bytes()
 	)
 
-	prevTx, err := chain.txStore.Get("737cb341c1dd4a34f78faeb9ae8ffc07b5e9cf987a1ef0bfb65d98542f48aacb")
+	prevTx, err := chain.txStore.Get("691648255378151fbc0ff8b4a044033c585c7f2ab1d0ce4e8284aed007d6c9a8")
 	assert.Nil(t, err)
 
 	inputs := []*proto.TxInput{
@@ -110,7 +149,7 @@ func TestAddBlockWithInsufficientFunds(t *testing.T) {
 		recipient  = crypto.GeneratePrivateKey().Public().Address().Bytes()
 	)
 
-	prevTx, err := chain.txStore.Get("737cb341c1dd4a34f78faeb9ae8ffc07b5e9cf987a1ef0bfb65d98542f48aacb")
+	prevTx, err := chain.txStore.Get("691648255378151fbc0ff8b4a044033c585c7f2ab1d0ce4e8284aed007d6c9a8")
 	assert.Nil(t, err)
 
 	inputs := []*proto.TxInput{
@@ -138,3 +177,509 @@ func TestAddBlockWithInsufficientFunds(t *testing.T) {
 	block.Transactions = append(block.Transactions, tx)
 	require.NotNil(t, chain.AddBlock(block))
 }
+
+func TestValidateTransactionAmounts(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+	)
+
+	for name, amounts := range map[string][]int64{
+		"overflowing sum":  {math.MaxInt64, math.MaxInt64, 1},
+		"above max money":  {types.MaxMoney + 1},
+		"summing past max": {types.MaxMoney, types.MaxMoney},
+		"zero amount":      {0, 1000},
+		"negative amount":  {-1, 1000},
+	} {
+		tx := spendGenesis(t, chain)
+		tx.Outputs = nil
+		for _, amount := range amounts {
+			tx.Outputs = append(tx.Outputs, &proto.TxOutput{Amount: amount, Address: recipient})
+		}
+		signInputs(tx)
+
+		assert.ErrorIs(t, chain.ValidateTransaction(tx), ErrInvalidOutput, name)
+	}
+}
+
+func TestAddBlockWithMultisig(t *testing.T) {
+	var (
+		chain      = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privateKey = crypto.NewPrivateKeyFromSeedString(godSeed)
+		alice      = crypto.GeneratePrivateKey()
+		bob        = crypto.GeneratePrivateKey()
+		carol      = crypto.GeneratePrivateKey()
+		lock       = types.NewMultisigLock(2, alice.Public(), bob.Public(), carol.Public())
+	)
+
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	lockTx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(genesis.Transactions[0]),
+				PrevOutIndex: 0,
+				PublicKey:    privateKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{types.NewMultisigOutput(1000, lock)},
+	}
+	lockTx.Inputs[0].Signature = types.SignTransaction(privateKey, lockTx).Bytes()
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, lockTx)
+	types.SignBlock(privateKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	spendTx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(lockTx),
+				PrevOutIndex: 0,
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  1000,
+				Address: bob.Public().Address().Bytes(),
+			},
+		},
+	}
+
+	require.Nil(t, types.CosignInput(alice, spendTx, 0, lock))
+	require.NotNil(t, chain.ValidateTransaction(spendTx))
+
+	require.Nil(t, types.CosignInput(bob, spendTx, 0, lock))
+	require.Nil(t, chain.ValidateTransaction(spendTx))
+
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, spendTx)
+	types.SignBlock(privateKey, block)
+	require.Nil(t, chain.AddBlock(block))
+}
+
+func TestAddBlockWithScript(t *testing.T) {
+	var (
+		chain      = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privateKey = crypto.NewPrivateKeyFromSeedString(godSeed)
+		recipient  = crypto.GeneratePrivateKey()
+		preimage   = []byte("escrow secret")
+		hash       = sha256.Sum256(preimage)
+	)
+
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	// the recipient can spend only together with the preimage
+	locking := append(script.HashLock(hash[:]), script.NewBuilder().
+		AddOp(script.OP_VERIFY).
+		AddData(recipient.Public().Bytes()).
+		AddOp(script.OP_CHECKSIG).
+		Script()...)
+
+	lockTx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(genesis.Transactions[0]),
+				PrevOutIndex: 0,
+				PublicKey:    privateKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{{Amount: 1000, LockingScript: locking}},
+	}
+	lockTx.Inputs[0].Signature = types.SignTransaction(privateKey, lockTx).Bytes()
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, lockTx)
+	types.SignBlock(privateKey, block)
+	require.Nil(t, chain.AddBlock(block))
+
+	spendTx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(lockTx),
+				PrevOutIndex: 0,
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  1000,
+				Address: recipient.Public().Address().Bytes(),
+			},
+		},
+	}
+	signature := types.SignTransaction(recipient, spendTx)
+
+	spendTx.Inputs[0].UnlockingScript = script.NewBuilder().AddData(signature.Bytes()).AddData([]byte("guess")).Script()
+	require.NotNil(t, chain.ValidateTransaction(spendTx))
+
+	spendTx.Inputs[0].UnlockingScript = script.NewBuilder().AddData(signature.Bytes()).AddData(preimage).Script()
+	require.Nil(t, chain.ValidateTransaction(spendTx))
+}
+
+func spendGenesis(t *testing.T, chain *Chain) *proto.Transaction {
+	var (
+		privateKey = crypto.NewPrivateKeyFromSeedString(godSeed)
+		recipient  = crypto.GeneratePrivateKey().Public().Address().Bytes()
+	)
+
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	return &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(genesis.Transactions[0]),
+				PrevOutIndex: 0,
+				PublicKey:    privateKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  1000,
+				Address: recipient,
+			},
+		},
+	}
+}
+
+func signInputs(tx *proto.Transaction) {
+	privateKey := crypto.NewPrivateKeyFromSeedString(godSeed)
+	signature := types.SignTransaction(privateKey, tx)
+	for _, input := range tx.Inputs {
+		input.Signature = signature.Bytes()
+	}
+}
+
+func TestValidateTransactionLockTime(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+
+	tx := spendGenesis(t, chain)
+	tx.LockTime = 2
+	signInputs(tx)
+
+	require.ErrorIs(t, chain.ValidateTransaction(tx), ErrTxNotFinal)
+	require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	require.ErrorIs(t, chain.ValidateTransaction(tx), ErrTxNotFinal)
+	require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	require.Nil(t, chain.ValidateTransaction(tx))
+
+	tx = spendGenesis(t, chain)
+	tx.LockTime = time.Now().Add(time.Hour).Unix()
+	signInputs(tx)
+	require.ErrorIs(t, chain.ValidateTransaction(tx), ErrTxNotFinal)
+
+	tx.LockTime = time.Now().Add(-time.Hour).Unix()
+	signInputs(tx)
+	require.Nil(t, chain.ValidateTransaction(tx))
+
+	// a premature transaction with a bad signature is rejected outright
+	tx.LockTime = 100
+	err := chain.ValidateTransaction(tx)
+	require.NotNil(t, err)
+	require.NotErrorIs(t, err, ErrTxNotFinal)
+}
+
+func TestValidateTransactionSequence(t *testing.T) {
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+
+	tx := spendGenesis(t, chain)
+	tx.Inputs[0].Sequence = types.NewSequence(3)
+	signInputs(tx)
+
+	for i := 0; i < 2; i++ {
+		require.ErrorIs(t, chain.ValidateTransaction(tx), ErrTxNotFinal)
+		require.Nil(t, chain.AddBlock(randomBlock(t, chain)))
+	}
+	require.Nil(t, chain.ValidateTransaction(tx))
+
+	tx.Inputs[0].Sequence = types.SequenceDisableFlag | types.NewSequence(100)
+	signInputs(tx)
+	require.Nil(t, chain.ValidateTransaction(tx))
+}
+
+func TestAddBlockWithDoubleSpend(t *testing.T) {
+	var (
+		chain      = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privateKey = crypto.NewPrivateKeyFromSeedString(godSeed)
+		first      = spendGenesis(t, chain)
+		second     = spendGenesis(t, chain)
+	)
+	signInputs(first)
+	signInputs(second)
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, first, second)
+	types.SignBlock(privateKey, block)
+	require.NotNil(t, chain.AddBlock(block))
+}
+
+func TestAddBlockWithDataOutput(t *testing.T) {
+	var (
+		chain   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		god     = crypto.NewPrivateKeyFromSeedString(godSeed).Public()
+		payload = util.RandomHash()
+		tx      = spendGenesis(t, chain)
+	)
+	tx.Outputs[0].Address = god.Address().Bytes()
+	tx.Outputs = append(tx.Outputs, types.NewDataOutput(payload))
+	signInputs(tx)
+
+	// anchorTx spends the first output of prev back to god next to output
+	anchorTx := func(prev *proto.Transaction, output *proto.TxOutput) *proto.Transaction {
+		tx := &proto.Transaction{
+			Version: 1,
+			Inputs: []*proto.TxInput{{
+				PrevTxHash: types.HashTransaction(prev),
+				PublicKey:  god.Bytes(),
+			}},
+			Outputs: []*proto.TxOutput{{Amount: 1000, Address: god.Address().Bytes()}, output},
+		}
+		signInputs(tx)
+
+		return tx
+	}
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	anchor, err := chain.GetAnchor(types.HashData(payload))
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(block), anchor.BlockHash)
+	assert.Equal(t, int32(1), anchor.Height)
+	assert.Equal(t, types.HashTransaction(tx), anchor.TxHash)
+	assert.Equal(t, uint32(1), anchor.OutIndex)
+
+	_, err = chain.GetAnchor(payload)
+	assert.NotNil(t, err)
+
+	// data outputs never enter the utxo set
+	_, err = chain.utxoStore.Get(utxoKey(types.HashTransaction(tx), 1))
+	assert.NotNil(t, err)
+
+	// anchoring the same payload again keeps the first anchor
+	again := anchorTx(tx, types.NewDataOutput(payload))
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, again)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	anchor, err = chain.GetAnchor(types.HashData(payload))
+	require.Nil(t, err)
+	assert.Equal(t, int32(1), anchor.Height)
+
+	tooLarge := anchorTx(again, types.NewDataOutput(make([]byte, types.MaxDataSize+1)))
+	assert.ErrorIs(t, chain.ValidateTransaction(tooLarge), ErrInvalidOutput)
+
+	withAmount := anchorTx(again, &proto.TxOutput{Amount: 1, Data: payload})
+	assert.ErrorIs(t, chain.ValidateTransaction(withAmount), ErrInvalidOutput)
+
+	// anchors have to spend an output, as does any transaction but issuances
+	free := &proto.Transaction{
+		Version: 1,
+		Outputs: []*proto.TxOutput{types.NewDataOutput(util.RandomHash())},
+	}
+	assert.ErrorIs(t, chain.ValidateTransaction(free), ErrNoInputs)
+	assert.ErrorIs(t, chain.ValidateTransaction(&proto.Transaction{Version: 1}), ErrNoInputs)
+}
+
+func TestAddBlockWithAsset(t *testing.T) {
+	var (
+		chain      = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		privateKey = crypto.NewPrivateKeyFromSeedString(godSeed)
+		issuer     = privateKey.Public().Address()
+		recipient  = crypto.GeneratePrivateKey().Public().Address()
+	)
+
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+
+	issuance, err := types.NewIssuance(types.HashTransaction(genesis.Transactions[0]), 0, "gold", 500, issuer)
+	require.Nil(t, err)
+	issuance.Inputs[0].PublicKey = privateKey.Public().Bytes()
+	issuance.Outputs = append(issuance.Outputs, &proto.TxOutput{Amount: 990, Address: issuer.Bytes()})
+
+	// minting more than the issued supply is rejected
+	issuance.Outputs[0].Amount = 501
+	signInputs(issuance)
+	assert.NotNil(t, chain.ValidateTransaction(issuance))
+
+	issuance.Outputs[0].Amount = 500
+	signInputs(issuance)
+	require.Nil(t, chain.ValidateTransaction(issuance))
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, issuance)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	assetID, err := types.IssuedAssetID(issuance)
+	require.Nil(t, err)
+
+	balance, err := chain.AssetBalance(issuer.Bytes(), assetID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(500), balance)
+	balance, err = chain.AssetBalance(issuer.Bytes(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, int64(990), balance)
+
+	issuanceHash := types.HashTransaction(issuance)
+	transfer := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   issuanceHash,
+				PrevOutIndex: 0,
+				PublicKey:    privateKey.Public().Bytes(),
+			},
+			{
+				PrevTxHash:   issuanceHash,
+				PrevOutIndex: 1,
+				PublicKey:    privateKey.Public().Bytes(),
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			types.NewAssetOutput(assetID, 200, recipient),
+			types.NewAssetOutput(assetID, 300, issuer),
+			// native coins cannot pay for asset outputs
+			types.NewAssetOutput(assetID, 100, issuer),
+			{Amount: 980, Address: issuer.Bytes()},
+		},
+	}
+	signInputs(transfer)
+	assert.NotNil(t, chain.ValidateTransaction(transfer))
+
+	transfer.Outputs = append(transfer.Outputs[:2], transfer.Outputs[3])
+	signInputs(transfer)
+	require.Nil(t, chain.ValidateTransaction(transfer))
+
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, transfer)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	balance, err = chain.AssetBalance(recipient.Bytes(), assetID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(200), balance)
+	balance, err = chain.AssetBalance(issuer.Bytes(), assetID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), balance)
+}
+
+func TestListUnspent(t *testing.T) {
+	var (
+		chain     = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		god       = crypto.NewPrivateKeyFromSeedString(godSeed).Public().Address().Bytes()
+		recipient = crypto.GeneratePrivateKey().Public().Address().Bytes()
+	)
+
+	utxos, err := chain.ListUnspent(god)
+	require.Nil(t, err)
+	require.Len(t, utxos, 1)
+	balance, err := chain.Balance(god)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1000), balance)
+
+	tx := spendGenesis(t, chain)
+	tx.Outputs = []*proto.TxOutput{
+		{Amount: 600, Address: recipient},
+		{Amount: 300, Address: recipient},
+		{Amount: 90, Address: god},
+	}
+	signInputs(tx)
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	utxos, err = chain.ListUnspent(recipient)
+	require.Nil(t, err)
+	require.Len(t, utxos, 2)
+	assert.Equal(t, 0, utxos[0].OutIndex)
+	assert.Equal(t, 1, utxos[1].OutIndex)
+	assert.Equal(t, 1, utxos[0].Height)
+
+	balance, err = chain.Balance(recipient)
+	require.Nil(t, err)
+	assert.Equal(t, int64(900), balance)
+	balance, err = chain.Balance(god)
+	require.Nil(t, err)
+	assert.Equal(t, int64(90), balance)
+
+	// the spent genesis output is gone from the store
+	_, err = chain.utxoStore.Get(utxoKey(tx.Inputs[0].PrevTxHash, 0))
+	assert.NotNil(t, err)
+}
+
+func TestTxIndex(t *testing.T) {
+	var (
+		chain = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		god   = crypto.NewPrivateKeyFromSeedString(godSeed).Public().Address().Bytes()
+		tx    = spendGenesis(t, chain)
+	)
+	tx.Outputs[0].Amount = 900
+	tx.Outputs = append(tx.Outputs, &proto.TxOutput{Amount: 100, Address: god})
+	signInputs(tx)
+
+	_, err := chain.GetTxLocation(types.HashTransaction(tx))
+	assert.ErrorIs(t, err, ErrTxIndexDisabled)
+
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	// blocks added before the index was enabled are indexed on rebuild
+	require.Nil(t, chain.EnableTxIndex(NewMemoryTxIndex()))
+
+	location, err := chain.GetTxLocation(types.HashTransaction(tx))
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(block), location.BlockHash)
+	assert.Equal(t, 1, location.Height)
+	assert.Equal(t, len(block.Transactions)-1, location.Index)
+	assert.Equal(t, 1, chain.Confirmations(location))
+
+	// blocks added afterwards are indexed incrementally
+	payment := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{{
+			PrevTxHash:   types.HashTransaction(tx),
+			PrevOutIndex: 1,
+			PublicKey:    crypto.NewPrivateKeyFromSeedString(godSeed).Public().Bytes(),
+		}},
+		Outputs: []*proto.TxOutput{{Amount: 100, Address: god}},
+	}
+	signInputs(payment)
+	block = randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, payment)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+	assert.Equal(t, 2, chain.Confirmations(location))
+
+	location, err = chain.GetTxLocation(types.HashTransaction(payment))
+	require.Nil(t, err)
+	assert.Equal(t, 2, location.Height)
+
+	genesis, err := chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	history, err := chain.AddressHistory(god)
+	require.Nil(t, err)
+	assert.Equal(t, [][]byte{
+		types.HashTransaction(genesis.Transactions[0]),
+		types.HashTransaction(tx),
+		types.HashTransaction(payment),
+	}, history)
+
+	history, err = chain.AddressHistory(tx.Outputs[0].Address)
+	require.Nil(t, err)
+	assert.Equal(t, [][]byte{types.HashTransaction(tx)}, history)
+}
@@ -0,0 +1,235 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"sync"
+)
+
+// subscriptionBuffer is how many events a subscriber may fall behind before
+// it is dropped.
+const subscriptionBuffer = 256
+
+var ErrSlowSubscriber = errors.New("subscriber is too slow to keep up with events")
+
+// EventFilter selects the events a subscription receives. Empty fields match
+// everything.
+type EventFilter struct {
+	Types     []proto.EventType
+	Addresses [][]byte
+	TxHashes  [][]byte
+}
+
+func NewEventFilter(req *proto.SubscribeRequest) EventFilter {
+	return EventFilter{
+		Types:     req.Types,
+		Addresses: req.Addresses,
+		TxHashes:  req.TxHashes,
+	}
+}
+
+// Match reports whether the event passes the filter. Block events pass when
+// any of their transactions does; reorg and peer events ignore the address
+// and transaction filters.
+func (f EventFilter) Match(ev *proto.Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == ev.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	switch {
+	case ev.Block != nil:
+		for _, tx := range ev.Block.Transactions {
+			if f.matchTx(tx) {
+				return true
+			}
+		}
+		return len(f.Addresses) == 0 && len(f.TxHashes) == 0
+	case ev.Transaction != nil:
+		return f.matchTx(ev.Transaction)
+	}
+
+	return true
+}
+
+func (f EventFilter) matchTx(tx *proto.Transaction) bool {
+	if len(f.TxHashes) > 0 {
+		hash := types.HashTransaction(tx)
+		found := false
+		for _, h := range f.TxHashes {
+			if bytes.Equal(h, hash) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Addresses) == 0 {
+		return true
+	}
+
+	for _, address := range f.Addresses {
+		for _, output := range tx.Outputs {
+			if bytes.Equal(output.Address, address) {
+				return true
+			}
+		}
+		for _, input := range tx.Inputs {
+			if len(input.PublicKey) == crypto.PublicKeyLen &&
+				bytes.Equal(crypto.PublicKeyFromBytes(input.PublicKey).Address().Bytes(), address) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type Subscription struct {
+	filter EventFilter
+	events chan *proto.Event
+	err    error
+}
+
+// Events delivers the events in the order they were published. The channel
+// is closed when the subscription ends, Err tells why.
+func (s *Subscription) Events() <-chan *proto.Event {
+	return s.events
+}
+
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// EventBus fans the events of the node out to its subscribers. Publishing
+// never blocks: a subscriber whose buffer is full is dropped with
+// ErrSlowSubscriber.
+type EventBus struct {
+	lock sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *EventBus) Subscribe(filter EventFilter) *Subscription {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	sub := &Subscription{
+		filter: filter,
+		events: make(chan *proto.Event, subscriptionBuffer),
+	}
+	b.subs[sub] = struct{}{}
+
+	return sub
+}
+
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.remove(sub, nil)
+}
+
+func (b *EventBus) Publish(ev *proto.Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for sub := range b.subs {
+		if !sub.filter.Match(ev) {
+			continue
+		}
+
+		select {
+		case sub.events <- ev:
+		default:
+			b.remove(sub, ErrSlowSubscriber)
+		}
+	}
+}
+
+func (b *EventBus) remove(sub *Subscription, err error) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	delete(b.subs, sub)
+	sub.err = err
+	close(sub.events)
+}
+
+// streamEvents sends the events matching the request until the context is
+// done or the subscription is dropped. When the request asks for a replay
+// the stored blocks from its height are sent first, without gaps or
+// duplicates towards the live events.
+func (n *Node) streamEvents(ctx context.Context, req *proto.SubscribeRequest, send func(*proto.Event) error) error {
+	var (
+		filter = NewEventFilter(req)
+		sub    = n.events.Subscribe(filter)
+	)
+	defer n.events.Unsubscribe(sub)
+
+	replayed := int32(-1)
+	if req.FromHeight != nil {
+		if *req.FromHeight < 0 {
+			return fmt.Errorf("invalid height (%d)", *req.FromHeight)
+		}
+
+		tip := n.chain.Height()
+		for height := int(*req.FromHeight); height <= tip; height++ {
+			block, err := n.chain.GetBlockByHeight(height)
+			if err != nil {
+				return err
+			}
+
+			ev := &proto.Event{
+				Type:   proto.EventType_EVENT_NEW_BLOCK,
+				Height: int32(height),
+				Block:  block,
+			}
+			if filter.Match(ev) {
+				if err := send(ev); err != nil {
+					return err
+				}
+			}
+			replayed = int32(height)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return sub.Err()
+			}
+			if ev.Type == proto.EventType_EVENT_NEW_BLOCK && ev.Height <= replayed {
+				continue
+			}
+			if err := send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pb "google.golang.org/protobuf/proto"
+	"testing"
+	"time"
+)
+
+func TestEventFilter(t *testing.T) {
+	var (
+		sender    = crypto.GeneratePrivateKey()
+		recipient = crypto.GeneratePrivateKey().Public().Address()
+		tx        = &proto.Transaction{
+			Version: 1,
+			Inputs:  []*proto.TxInput{{PublicKey: sender.Public().Bytes()}},
+			Outputs: []*proto.TxOutput{{Amount: 1, Address: recipient.Bytes()}},
+		}
+		txEvent    = &proto.Event{Type: proto.EventType_EVENT_NEW_MEMPOOL_TX, Transaction: tx}
+		blockEvent = &proto.Event{Type: proto.EventType_EVENT_NEW_BLOCK, Block: &proto.Block{Transactions: []*proto.Transaction{tx}}}
+		peerEvent  = &proto.Event{Type: proto.EventType_EVENT_PEER_CONNECTED, Peer: &proto.Version{}}
+	)
+
+	assert.True(t, EventFilter{}.Match(txEvent))
+
+	byType := EventFilter{Types: []proto.EventType{proto.EventType_EVENT_NEW_BLOCK}}
+	assert.False(t, byType.Match(txEvent))
+	assert.True(t, byType.Match(blockEvent))
+
+	for _, address := range []crypto.Address{recipient, sender.Public().Address()} {
+		byAddress := EventFilter{Addresses: [][]byte{address.Bytes()}}
+		assert.True(t, byAddress.Match(txEvent))
+		assert.True(t, byAddress.Match(blockEvent))
+		assert.True(t, byAddress.Match(peerEvent))
+	}
+
+	other := EventFilter{Addresses: [][]byte{crypto.GeneratePrivateKey().Public().Address().Bytes()}}
+	assert.False(t, other.Match(txEvent))
+	assert.False(t, other.Match(blockEvent))
+	assert.False(t, other.Match(&proto.Event{Type: proto.EventType_EVENT_NEW_BLOCK, Block: &proto.Block{}}))
+
+	byHash := EventFilter{TxHashes: [][]byte{types.HashTransaction(tx)}}
+	assert.True(t, byHash.Match(txEvent))
+	byHash.TxHashes[0] = make([]byte, 32)
+	assert.False(t, byHash.Match(txEvent))
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(EventFilter{})
+	slow := bus.Subscribe(EventFilter{})
+
+	for i := 0; i < subscriptionBuffer; i++ {
+		bus.Publish(&proto.Event{Height: int32(i)})
+		<-sub.Events()
+	}
+
+	// the subscriber that never reads overflows and is dropped
+	bus.Publish(&proto.Event{Height: subscriptionBuffer})
+	for range slow.Events() {
+	}
+	assert.ErrorIs(t, slow.Err(), ErrSlowSubscriber)
+
+	ev := <-sub.Events()
+	assert.Equal(t, int32(subscriptionBuffer), ev.Height)
+
+	bus.Unsubscribe(sub)
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.Nil(t, sub.Err())
+}
+
+func TestStreamEventsResume(t *testing.T) {
+	n := NewNode(ServerConfig{})
+	for i := 0; i < 3; i++ {
+		require.Nil(t, n.addBlock(context.Background(), randomBlock(t, n.chain)))
+	}
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		events      = make(chan *proto.Event, 10)
+		done        = make(chan error)
+	)
+	go func() {
+		done <- n.streamEvents(ctx, &proto.SubscribeRequest{FromHeight: pb.Int32(2)}, func(ev *proto.Event) error {
+			events <- ev
+			return nil
+		})
+	}()
+
+	for _, height := range []int32{2, 3} {
+		ev := <-events
+		assert.Equal(t, proto.EventType_EVENT_NEW_BLOCK, ev.Type)
+		assert.Equal(t, height, ev.Height)
+	}
+
+	// a block added while replaying is sent exactly once
+	require.Nil(t, n.addBlock(context.Background(), randomBlock(t, n.chain)))
+	ev := <-events
+	assert.Equal(t, int32(4), ev.Height)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Empty(t, events)
+}
+
+func TestStreamEventsReplayFromGenesis(t *testing.T) {
+	n := NewNode(ServerConfig{})
+	require.Nil(t, n.addBlock(context.Background(), randomBlock(t, n.chain)))
+
+	replay := func(req *proto.SubscribeRequest) ([]int32, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		var heights []int32
+		err := n.streamEvents(ctx, req, func(ev *proto.Event) error {
+			heights = append(heights, ev.Height)
+			return nil
+		})
+		return heights, err
+	}
+
+	heights, err := replay(&proto.SubscribeRequest{FromHeight: pb.Int32(0)})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []int32{0, 1}, heights)
+
+	// without a height only live events are sent
+	heights, err = replay(&proto.SubscribeRequest{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, heights)
+
+	_, err = replay(&proto.SubscribeRequest{FromHeight: pb.Int32(-1)})
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,140 @@
+package node
+
+import (
+	"fmt"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultSyncTolerance is how many blocks a node may trail the best known
+	// height and still be ready, unless ServerConfig.SyncTolerance says
+	// otherwise.
+	defaultSyncTolerance = 2
+	// missedBlocksTolerance is how many block times a validator may go
+	// without producing a block before it counts as stalled.
+	missedBlocksTolerance = 3
+)
+
+// Names of the health checks.
+const (
+	checkStore     = "store"
+	checkValidator = "validator"
+	checkPeers     = "peers"
+	checkSync      = "sync"
+)
+
+// BestHeight is the highest height of the connected peers, or our own when
+// higher. The height of a peer only counts while it stays connected, so one
+// claiming too much is forgotten once it is dropped.
+func (n *Node) BestHeight() int {
+	best := n.chain.Height()
+	for _, v := range n.getPeerVersions() {
+		if int(v.Height) > best {
+			best = int(v.Height)
+		}
+	}
+
+	return best
+}
+
+func (n *Node) syncTolerance() int {
+	if n.SyncTolerance > 0 {
+		return n.SyncTolerance
+	}
+
+	return defaultSyncTolerance
+}
+
+// Health runs the health checks. A live node works on its own: its store
+// answers. A ready node is also connected, synced with the best known height
+// and, for validators, produces blocks on schedule. A stalled validator is
+// not restarted for it, as it may just wait for peers.
+func (n *Node) Health() (live bool, ready bool, checks []*proto.HealthCheck) {
+	var (
+		store     = &proto.HealthCheck{Name: checkStore, Ok: true}
+		validator = &proto.HealthCheck{Name: checkValidator, Ok: true}
+		peers     = &proto.HealthCheck{Name: checkPeers, Ok: true}
+		sync      = &proto.HealthCheck{Name: checkSync, Ok: true}
+	)
+
+	if _, err := n.chain.GetBlockByHeight(n.chain.Height()); err != nil {
+		store.Ok = false
+		store.Detail = err.Error()
+	}
+
+	if n.PrivateKey != nil {
+		last := time.Unix(0, n.lastProduced.Load())
+		if since := time.Since(last); since > missedBlocksTolerance*blockTime {
+			validator.Ok = false
+			validator.Detail = fmt.Sprintf("no block produced for %s", since.Round(time.Second))
+		}
+	}
+
+	if len(n.getPeerList()) == 0 {
+		peers.Ok = false
+		peers.Detail = "no connected peers"
+	}
+
+	if behind := n.BestHeight() - n.chain.Height(); behind > n.syncTolerance() {
+		sync.Ok = false
+		sync.Detail = fmt.Sprintf("%d blocks behind the best known height", behind)
+	}
+
+	live = store.Ok
+	ready = live && validator.Ok && peers.Ok && sync.Ok
+
+	return live, ready, []*proto.HealthCheck{store, validator, peers, sync}
+}
+
+// Status summarizes the node for operators and orchestration.
+func (n *Node) Status() (*proto.Status, error) {
+	genesis, err := n.chain.GetBlockByHeight(0)
+	if err != nil {
+		return nil, err
+	}
+	height := n.chain.Height()
+	tip, err := n.chain.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	best := n.BestHeight()
+	progress := 1.0
+	if best > 0 && height < best {
+		progress = float64(height) / float64(best)
+	}
+
+	live, ready, checks := n.Health()
+
+	return &proto.Status{
+		Version:      n.Version,
+		ChainId:      types.HashBlock(genesis),
+		Height:       int32(height),
+		TipHash:      types.HashBlock(tip),
+		BestHeight:   int32(best),
+		SyncProgress: progress,
+		MempoolSize:  int32(n.mempool.Len()),
+		PeerCount:    int32(len(n.getPeerList())),
+		Live:         live,
+		Ready:        ready,
+		Checks:       checks,
+	}, nil
+}
+
+// healthHandler serves the liveness, or with readiness set the readiness, of
+// the node for probes: 200 when it passes, 503 otherwise, with the checks as
+// the body.
+func healthHandler(n *Node, readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		live, ready, checks := n.Health()
+
+		status := http.StatusOK
+		if !live || readiness && !ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, newJSONHealth(live, ready, checks))
+	}
+}
@@ -0,0 +1,124 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func failedChecks(checks []*proto.HealthCheck) []string {
+	var names []string
+	for _, check := range checks {
+		if !check.Ok {
+			names = append(names, check.Name)
+		}
+	}
+
+	return names
+}
+
+// addUnreachablePeer connects a peer that is never dialed successfully.
+func addUnreachablePeer(t *testing.T, n *Node, height int32) {
+	p, err := n.dialPeer("localhost:1", "")
+	require.Nil(t, err)
+
+	n.addPeer(p, &proto.Version{ListenAddr: "localhost:1", Height: height, PublicKey: randomNodeKey()})
+}
+
+func TestHealth(t *testing.T) {
+	n := NewNode(ServerConfig{Version: "test"})
+
+	live, ready, checks := n.Health()
+	assert.True(t, live)
+	assert.False(t, ready)
+	assert.Equal(t, []string{checkPeers}, failedChecks(checks))
+
+	// a peer far ahead of us
+	addUnreachablePeer(t, n, 4)
+	live, ready, checks = n.Health()
+	assert.True(t, live)
+	assert.False(t, ready)
+	assert.Equal(t, []string{checkSync}, failedChecks(checks))
+
+	status, err := n.Status()
+	require.Nil(t, err)
+	assert.Equal(t, "test", status.Version)
+	assert.Equal(t, int32(4), status.BestHeight)
+	assert.Equal(t, 0.0, status.SyncProgress)
+	assert.Equal(t, int32(1), status.PeerCount)
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	assert.Equal(t, types.HashBlock(genesis), status.ChainId)
+
+	// within the sync tolerance
+	for i := 1; i <= 2; i++ {
+		block := randomBlock(t, n.chain)
+		block.Header.Height = int32(i)
+		types.SignBlock(crypto.GeneratePrivateKey(), block)
+		_, err := n.HandleBlock(context.Background(), block)
+		require.Nil(t, err)
+	}
+	live, ready, _ = n.Health()
+	assert.True(t, live)
+	assert.True(t, ready)
+
+	status, err = n.Status()
+	require.Nil(t, err)
+	assert.Equal(t, int32(2), status.Height)
+	assert.Equal(t, 0.5, status.SyncProgress)
+
+	// blocks we cannot validate do not raise the best known height
+	ahead := randomBlock(t, NewChain(NewMemoryBlockStore(), NewMemoryTXStore()))
+	ahead.Header.Height = 10
+	types.SignBlock(crypto.GeneratePrivateKey(), ahead)
+	_, err = n.HandleBlock(context.Background(), ahead)
+	require.NotNil(t, err)
+	assert.Equal(t, 4, n.BestHeight())
+
+	// nor do peers once they are gone
+	for _, p := range n.getPeers() {
+		n.deletePeer(p)
+	}
+	assert.Equal(t, 2, n.BestHeight())
+
+	// a validator that stopped producing blocks stays live but is not ready
+	addUnreachablePeer(t, n, 2)
+	n.PrivateKey = crypto.GeneratePrivateKey()
+	n.lastProduced.Store(time.Now().Add(-missedBlocksTolerance * 2 * blockTime).UnixNano())
+	live, ready, checks = n.Health()
+	assert.True(t, live)
+	assert.False(t, ready)
+	assert.Equal(t, []string{checkValidator}, failedChecks(checks))
+}
+
+func TestHealthProbes(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{})
+		server = httptest.NewServer(NewHTTPServer(n))
+	)
+	defer server.Close()
+
+	var health jsonHealth
+	assert.Equal(t, http.StatusOK, getJSON(t, server, "/healthz", &health))
+	assert.True(t, health.Live)
+	assert.Equal(t, http.StatusServiceUnavailable, getJSON(t, server, "/readyz", &health))
+	assert.False(t, health.Ready)
+
+	addUnreachablePeer(t, n, 0)
+	assert.Equal(t, http.StatusOK, getJSON(t, server, "/readyz", &health))
+	assert.True(t, health.Ready)
+
+	var status jsonStatus
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/status", &status))
+	assert.Equal(t, int32(1), status.PeerCount)
+	assert.Equal(t, 1.0, status.SyncProgress)
+	assert.True(t, status.Ready)
+	assert.Len(t, status.Checks, 4)
+}
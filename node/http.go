@@ -0,0 +1,471 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/gorilla/websocket"
+	pb "google.golang.org/protobuf/proto"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultBlockPageSize = 20
+	maxBlockPageSize     = 100
+	// maxBodySize caps request bodies like maxRPCBodySize does for JSON-RPC.
+	maxBodySize = 1 << 20
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// httpError carries the status code an API error is reported with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return &httpError{status: http.StatusBadRequest, err: err}
+}
+
+func notFound(err error) error {
+	return &httpError{status: http.StatusNotFound, err: err}
+}
+
+type apiFunc func(r *http.Request) (any, error)
+
+// HTTPServer is the JSON gateway to the queries of the node and transaction
+// submission. The routes are described by /openapi.json.
+type HTTPServer struct {
+	node  *Node
+	query *QueryServer
+	mux   *http.ServeMux
+}
+
+func NewHTTPServer(node *Node) *HTTPServer {
+	s := &HTTPServer{
+		node:  node,
+		query: NewQueryServer(node),
+		mux:   http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/openapi.json", s.serveOpenAPI)
+	s.mux.HandleFunc("/healthz", healthHandler(node, false))
+	s.mux.HandleFunc("/readyz", healthHandler(node, true))
+	s.mux.HandleFunc("/v1/status", s.handle(http.MethodGet, s.getStatus))
+	s.mux.HandleFunc("/v1/tip", s.handle(http.MethodGet, s.getTip))
+	s.mux.HandleFunc("/v1/blocks", s.handle(http.MethodGet, s.listBlocks))
+	s.mux.HandleFunc("/v1/blocks/", s.handle(http.MethodGet, s.getBlock))
+	s.mux.HandleFunc("/v1/txs", s.handle(http.MethodPost, s.submitTransaction))
+	s.mux.HandleFunc("/v1/txs/", s.handle(http.MethodGet, s.getTransaction))
+	s.mux.HandleFunc("/v1/utxos/", s.handle(http.MethodGet, s.getUTXO))
+	s.mux.HandleFunc("/v1/addresses/", s.handle(http.MethodGet, s.getAddress))
+	s.mux.HandleFunc("/v1/mempool", s.handle(http.MethodGet, s.getMempool))
+	s.mux.HandleFunc("/v1/peers", s.handle(http.MethodGet, s.getPeers))
+	s.mux.HandleFunc("/v1/events", s.serveEvents)
+
+	return s
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.node.isBannedRequest(r) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "banned"})
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *HTTPServer) handle(method string, fn apiFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+
+		v, err := fn(r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var httpErr *httpError
+			if errors.As(err, &httpErr) {
+				status = httpErr.status
+			}
+			writeJSON(w, status, map[string]string{"error": err.Error()})
+			return
+		}
+
+		status := http.StatusOK
+		if method == http.MethodPost {
+			status = http.StatusAccepted
+		}
+		writeJSON(w, status, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *HTTPServer) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// pathParams returns the path segments following the prefix.
+func pathParams(r *http.Request, prefix string) []string {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if rest == "" {
+		return nil
+	}
+
+	return strings.Split(rest, "/")
+}
+
+func parseHash(s string) ([]byte, error) {
+	hash, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, badRequest(fmt.Errorf("invalid hash [%s]", s))
+	}
+
+	return hash, nil
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || v < 0 {
+		return 0, badRequest(fmt.Errorf("invalid %s [%s]", name, s))
+	}
+
+	return int(v), nil
+}
+
+func (s *HTTPServer) getTip(r *http.Request) (any, error) {
+	tip, err := s.query.GetTip(r.Context(), &proto.TipRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonTip{
+		Height: tip.Height,
+		Hash:   tip.Hash,
+		Header: newJSONHeader(tip.Header),
+	}, nil
+}
+
+// listBlocks returns a page of blocks in ascending height starting at the
+// from query parameter.
+func (s *HTTPServer) listBlocks(r *http.Request) (any, error) {
+	from, err := queryInt(r, "from", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryInt(r, "limit", defaultBlockPageSize)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 || limit > maxBlockPageSize {
+		limit = maxBlockPageSize
+	}
+
+	var (
+		height = s.node.chain.Height()
+		page   = &jsonBlockPage{Blocks: []*jsonBlock{}}
+	)
+	for h := from; h <= height && h < from+limit; h++ {
+		block, err := s.node.chain.GetBlockByHeight(h)
+		if err != nil {
+			return nil, err
+		}
+		page.Blocks = append(page.Blocks, newJSONBlock(block))
+	}
+
+	if next := from + limit; next <= height {
+		nextFrom := int32(next)
+		page.NextFrom = &nextFrom
+	}
+
+	return page, nil
+}
+
+// getBlock returns the block with the hash or height given in the path.
+func (s *HTTPServer) getBlock(r *http.Request) (any, error) {
+	params := pathParams(r, "/v1/blocks/")
+	if len(params) != 1 {
+		return nil, notFound(fmt.Errorf("unknown route [%s]", r.URL.Path))
+	}
+
+	// block hashes are 64 hex characters, anything shorter is a height
+	req := &proto.BlockRequest{}
+	if len(params[0]) == 2*sha256.Size {
+		hash, err := parseHash(params[0])
+		if err != nil {
+			return nil, err
+		}
+		req.Hash = hash
+	} else {
+		height, err := strconv.ParseInt(params[0], 10, 32)
+		if err != nil || height < 0 {
+			return nil, badRequest(fmt.Errorf("invalid block hash or height [%s]", params[0]))
+		}
+		req.Height = int32(height)
+	}
+
+	block, err := s.query.GetBlock(r.Context(), req)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return newJSONBlock(block), nil
+}
+
+func (s *HTTPServer) getTransaction(r *http.Request) (any, error) {
+	params := pathParams(r, "/v1/txs/")
+	if len(params) != 1 {
+		return nil, notFound(fmt.Errorf("unknown route [%s]", r.URL.Path))
+	}
+
+	hash, err := parseHash(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.query.GetTransaction(r.Context(), &proto.TxRequest{Hash: hash})
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return newJSONTxInfo(info), nil
+}
+
+func (s *HTTPServer) submitTransaction(r *http.Request) (any, error) {
+	var j jsonTransaction
+	if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+		return nil, badRequest(fmt.Errorf("invalid transaction: %w", err))
+	}
+
+	tx := j.proto()
+	if _, err := s.node.HandleTransaction(r.Context(), tx); err != nil {
+		return nil, badRequest(err)
+	}
+
+	return &jsonSubmitted{Hash: types.HashTransaction(tx)}, nil
+}
+
+func (s *HTTPServer) getUTXO(r *http.Request) (any, error) {
+	params := pathParams(r, "/v1/utxos/")
+	if len(params) != 2 {
+		return nil, notFound(fmt.Errorf("unknown route [%s]", r.URL.Path))
+	}
+
+	hash, err := parseHash(params[0])
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.ParseUint(params[1], 10, 32)
+	if err != nil {
+		return nil, badRequest(fmt.Errorf("invalid output index [%s]", params[1]))
+	}
+
+	utxo, err := s.query.GetUTXO(r.Context(), &proto.UTXORequest{TxHash: hash, OutIndex: uint32(index)})
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return newJSONUTXO(utxo), nil
+}
+
+// getAddress serves /v1/addresses/{address}/utxos and
+// /v1/addresses/{address}/balance.
+func (s *HTTPServer) getAddress(r *http.Request) (any, error) {
+	params := pathParams(r, "/v1/addresses/")
+	if len(params) != 2 {
+		return nil, notFound(fmt.Errorf("unknown route [%s]", r.URL.Path))
+	}
+
+	address, err := crypto.ParseAddress(params[0])
+	if err != nil {
+		return nil, badRequest(err)
+	}
+
+	switch params[1] {
+	case "utxos":
+		resp, err := s.node.ListUnspent(r.Context(), &proto.UnspentRequest{Address: address.Bytes()})
+		if err != nil {
+			return nil, err
+		}
+
+		utxos := make([]*jsonUTXO, len(resp.Outputs))
+		for i, utxo := range resp.Outputs {
+			utxos[i] = newJSONUTXO(utxo)
+		}
+
+		return utxos, nil
+	case "balance":
+		var assetID []byte
+		if s := r.URL.Query().Get("asset"); s != "" {
+			if assetID, err = parseHash(s); err != nil {
+				return nil, err
+			}
+		}
+
+		balance, err := s.node.GetBalance(r.Context(), &proto.BalanceRequest{Address: address.Bytes(), AssetId: assetID})
+		if err != nil {
+			return nil, err
+		}
+
+		return &jsonBalance{
+			Address: address.Bytes(),
+			AssetID: assetID,
+			Amount:  balance.Amount,
+		}, nil
+	}
+
+	return nil, notFound(fmt.Errorf("unknown route [%s]", r.URL.Path))
+}
+
+func (s *HTTPServer) getMempool(r *http.Request) (any, error) {
+	mempool, err := s.query.GetMempool(r.Context(), &proto.MempoolRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonMempool{
+		Transactions: newJSONTransactions(mempool.Transactions),
+		Held:         newJSONTransactions(mempool.Held),
+	}, nil
+}
+
+func (s *HTTPServer) getPeers(r *http.Request) (any, error) {
+	resp, err := s.query.GetPeers(r.Context(), &proto.PeersRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]*jsonPeer, len(resp.Peers))
+	for i, v := range resp.Peers {
+		peers[i] = newJSONPeer(v)
+	}
+
+	return peers, nil
+}
+
+func (s *HTTPServer) getStatus(r *http.Request) (any, error) {
+	status, err := s.query.GetStatus(r.Context(), &proto.StatusRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJSONStatus(status), nil
+}
+
+var upgrader = websocket.Upgrader{
+	// the gateway serves any origin, like the JSON routes
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func eventTypeName(t proto.EventType) string {
+	return strings.ToLower(strings.TrimPrefix(t.String(), "EVENT_"))
+}
+
+// parseSubscribeRequest reads the filters of an event subscription from the
+// type, address and tx query parameters, each repeatable, and the resume
+// height from from.
+func parseSubscribeRequest(r *http.Request) (*proto.SubscribeRequest, error) {
+	var (
+		query = r.URL.Query()
+		req   = &proto.SubscribeRequest{}
+	)
+
+	for _, name := range query["type"] {
+		t, ok := proto.EventType_value["EVENT_"+strings.ToUpper(name)]
+		if !ok {
+			return nil, badRequest(fmt.Errorf("unknown event type [%s]", name))
+		}
+		req.Types = append(req.Types, proto.EventType(t))
+	}
+	for _, s := range query["address"] {
+		address, err := crypto.ParseAddress(s)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		req.Addresses = append(req.Addresses, address.Bytes())
+	}
+	for _, s := range query["tx"] {
+		hash, err := parseHash(s)
+		if err != nil {
+			return nil, err
+		}
+		req.TxHashes = append(req.TxHashes, hash)
+	}
+
+	if r.URL.Query().Has("from") {
+		from, err := queryInt(r, "from", 0)
+		if err != nil {
+			return nil, err
+		}
+		req.FromHeight = pb.Int32(int32(from))
+	}
+
+	return req, nil
+}
+
+// serveEvents upgrades to a WebSocket and streams the subscribed events as
+// JSON messages until either side closes the connection.
+func (s *HTTPServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	req, err := parseSubscribeRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// reading handles control frames and notices the client going away
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	err = s.node.streamEvents(ctx, req, func(ev *proto.Event) error {
+		return conn.WriteJSON(newJSONEvent(ev))
+	})
+	if errors.Is(err, ErrSlowSubscriber) {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()))
+	}
+}
@@ -0,0 +1,229 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getJSON(t *testing.T, server *httptest.Server, path string, v any) int {
+	resp, err := http.Get(server.URL + path)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	if v != nil {
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+
+	return resp.StatusCode
+}
+
+func TestHTTPServer(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{})
+		server = httptest.NewServer(NewHTTPServer(n))
+	)
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, n.chain.AddBlock(randomBlock(t, n.chain)))
+	}
+
+	var tip jsonTip
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/tip", &tip))
+	assert.Equal(t, int32(3), tip.Height)
+
+	var page jsonBlockPage
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/blocks?from=1&limit=2", &page))
+	require.Len(t, page.Blocks, 2)
+	first, err := n.chain.GetBlockByHeight(1)
+	require.Nil(t, err)
+	assert.Equal(t, hexBytes(types.HashBlock(first)), page.Blocks[0].Hash)
+	require.NotNil(t, page.NextFrom)
+	assert.Equal(t, int32(3), *page.NextFrom)
+
+	page = jsonBlockPage{}
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/blocks?from=3", &page))
+	require.Len(t, page.Blocks, 1)
+	assert.Nil(t, page.NextFrom)
+
+	var block jsonBlock
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/blocks/"+hex.EncodeToString(tip.Hash), &block))
+	assert.Equal(t, tip.Hash, block.Hash)
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/blocks/3", &block))
+	assert.Equal(t, tip.Hash, block.Hash)
+	assert.Equal(t, http.StatusNotFound, getJSON(t, server, "/v1/blocks/4", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, server, "/v1/blocks/tip", nil))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, server, "/v1/blocks/-1", nil))
+	// heights beyond int32 are not truncated into negative ones
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, server, "/v1/blocks/4294967295", nil))
+
+	// submit a transaction in its JSON form
+	tx := spendGenesis(t, n.chain)
+	signInputs(tx)
+	body, err := json.Marshal(newJSONTransaction(tx))
+	require.Nil(t, err)
+
+	resp, err := http.Post(server.URL+"/v1/txs", "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.True(t, n.mempool.Has(tx))
+
+	var mempool jsonMempool
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/mempool", &mempool))
+	require.Len(t, mempool.Transactions, 1)
+	assert.Equal(t, hexBytes(types.HashTransaction(tx)), mempool.Transactions[0].Hash)
+
+	tx.Outputs[0].Amount = 2000
+	body, err = json.Marshal(newJSONTransaction(tx))
+	require.Nil(t, err)
+	resp, err = http.Post(server.URL+"/v1/txs", "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, getJSON(t, server, "/v1/txs", nil))
+
+	// bodies are read up to maxBodySize
+	body = append([]byte(`{"version":1,"padding":"`), bytes.Repeat([]byte("a"), maxBodySize)...)
+	resp, err = http.Post(server.URL+"/v1/txs", "application/json", bytes.NewReader(append(body, `"}`...)))
+	require.Nil(t, err)
+	var apiErr map[string]string
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&apiErr))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, apiErr["error"], "too large")
+
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	genesisTxHash := hex.EncodeToString(types.HashTransaction(genesis.Transactions[0]))
+
+	var info jsonTxInfo
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/txs/"+genesisTxHash, &info))
+	assert.Equal(t, int64(1000), info.Transaction.Outputs[0].Amount)
+	assert.Nil(t, info.Height)
+	assert.Nil(t, info.Index)
+
+	god := crypto.NewPrivateKeyFromSeedString(godSeed).Public().Address()
+
+	var utxo jsonUTXO
+	require.Equal(t, http.StatusOK, getJSON(t, server, fmt.Sprintf("/v1/utxos/%s/0", genesisTxHash), &utxo))
+	assert.Equal(t, addressBytes(god.Bytes()), utxo.Address)
+	assert.Equal(t, http.StatusNotFound, getJSON(t, server, fmt.Sprintf("/v1/utxos/%s/1", genesisTxHash), nil))
+
+	var utxos []*jsonUTXO
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/addresses/"+god.String()+"/utxos", &utxos))
+	assert.Len(t, utxos, 1)
+
+	var balance jsonBalance
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/addresses/"+god.String()+"/balance", &balance))
+	assert.Equal(t, int64(1000), balance.Amount)
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, server, "/v1/addresses/"+god.Hex()+"/balance", nil))
+
+	assert.Equal(t, http.StatusOK, getJSON(t, server, "/openapi.json", nil))
+}
+
+func TestHTTPServerEvents(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{})
+		server = httptest.NewServer(NewHTTPServer(n))
+	)
+	defer server.Close()
+
+	require.Nil(t, n.addBlock(context.Background(), randomBlock(t, n.chain)))
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/events?type=new_block&from=1"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Nil(t, err)
+	defer conn.Close()
+
+	var ev jsonEvent
+	require.Nil(t, conn.ReadJSON(&ev))
+	assert.Equal(t, "new_block", ev.Type)
+	assert.Equal(t, int32(1), ev.Height)
+
+	// transactions are filtered out by type
+	tx := spendGenesis(t, n.chain)
+	signInputs(tx)
+	_, err = n.HandleTransaction(context.Background(), tx)
+	require.Nil(t, err)
+	require.Nil(t, n.addBlock(context.Background(), randomBlock(t, n.chain)))
+
+	require.Nil(t, conn.ReadJSON(&ev))
+	assert.Equal(t, "new_block", ev.Type)
+	assert.Equal(t, int32(2), ev.Height)
+
+	_, resp, err := websocket.DefaultDialer.Dial(strings.Replace(url, "new_block", "unknown", 1), nil)
+	require.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHTTPServerRefusesBannedIPs(t *testing.T) {
+	var (
+		n          = NewNode(ServerConfig{})
+		server     = httptest.NewServer(NewHTTPServer(n))
+		rpc        = httptest.NewServer(NewJSONRPCServer(n))
+		tip        jsonTip
+		statusBody = `{"jsonrpc":"2.0","id":1,"method":"node_status"}`
+	)
+	defer server.Close()
+	defer rpc.Close()
+
+	require.Nil(t, n.bans.Ban("127.0.0.1", time.Hour, "test"))
+	assert.Equal(t, http.StatusForbidden, getJSON(t, server, "/v1/tip", &tip))
+	resp := postRPC(t, rpc, statusBody)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	require.Nil(t, n.bans.Unban("127.0.0.1"))
+	assert.Equal(t, http.StatusOK, getJSON(t, server, "/v1/tip", &tip))
+	resp = postRPC(t, rpc, statusBody)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestParseSubscribeRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/events?type=reorg&type=peer_connected&from=0", nil)
+	req, err := parseSubscribeRequest(r)
+	require.Nil(t, err)
+	assert.Equal(t, []proto.EventType{proto.EventType_EVENT_REORG, proto.EventType_EVENT_PEER_CONNECTED}, req.Types)
+	require.NotNil(t, req.FromHeight)
+	assert.Equal(t, int32(0), *req.FromHeight)
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/events?type=unknown", nil)
+	_, err = parseSubscribeRequest(r)
+	assert.NotNil(t, err)
+}
+
+func TestHTTPServerTxLocation(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{TxIndex: true})
+		server = httptest.NewServer(NewHTTPServer(n))
+	)
+	defer server.Close()
+
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	genesisTxHash := hex.EncodeToString(types.HashTransaction(genesis.Transactions[0]))
+
+	// the genesis transaction is first in block 0, zeros are reported
+	var info map[string]any
+	require.Equal(t, http.StatusOK, getJSON(t, server, "/v1/txs/"+genesisTxHash, &info))
+	assert.Equal(t, 0.0, info["height"])
+	assert.Equal(t, 0.0, info["index"])
+	assert.Equal(t, 1.0, info["confirmations"])
+}
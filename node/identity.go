@@ -0,0 +1,289 @@
+package node
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	challengeLen = 32
+	// maxPendingChallenges bounds the challenges handed out and not yet
+	// answered.
+	maxPendingChallenges = 1024
+	// handshakeDomain separates handshake signatures from any other use of
+	// the node key.
+	handshakeDomain = "blocker handshake"
+)
+
+// LoadNodeKey reads the hex encoded seed of the node key at path, generating
+// and saving a new key when there is none yet.
+func LoadNodeKey(path string) (*crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		key := crypto.GeneratePrivateKey()
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())), 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != crypto.SeedLen {
+		return nil, fmt.Errorf("invalid node key in %s", path)
+	}
+
+	return crypto.NewPrivateKeyFromSeed(seed), nil
+}
+
+// NodeID identifies a node by its hex encoded public node key.
+func NodeID(key *crypto.PublicKey) string {
+	return hex.EncodeToString(key.Bytes())
+}
+
+// ID returns the node ID of the node.
+func (n *Node) ID() string {
+	return NodeID(n.NodeKey.Public())
+}
+
+// serverCredentials accepts peers authenticating with their node key, and
+// clients without any.
+func (n *Node) serverCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(crypto.NewTLSConfig(&n.cert, true, func(*crypto.PublicKey) error {
+		return nil
+	}))
+}
+
+// clientCredentials authenticates us with the node key. With an ID only the
+// node with that ID is accepted.
+func (n *Node) clientCredentials(id string) credentials.TransportCredentials {
+	return credentials.NewTLS(crypto.NewTLSConfig(&n.cert, false, func(key *crypto.PublicKey) error {
+		if id != "" && NodeID(key) != id {
+			return fmt.Errorf("node %s answered instead of %s", NodeID(key), id)
+		}
+		return nil
+	}))
+}
+
+// peerKey returns the node key the calling peer authenticated with.
+func peerKey(ctx context.Context) (*crypto.PublicKey, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	return tlsPeerKey(p)
+}
+
+func tlsPeerKey(p *peer.Peer) (*crypto.PublicKey, bool) {
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil, false
+	}
+
+	key, err := crypto.TLSPublicKey(info.State.PeerCertificates[0])
+	if err != nil {
+		return nil, false
+	}
+
+	return key, true
+}
+
+type pendingChallenge struct {
+	nonce   []byte
+	expires time.Time
+}
+
+// challenges holds the nonces handed out to callers by node ID. Each is
+// answered at most once, within handshakeTimeout.
+type challenges struct {
+	lock    sync.Mutex
+	pending map[string]pendingChallenge
+}
+
+func newChallenges() *challenges {
+	return &challenges{
+		pending: make(map[string]pendingChallenge),
+	}
+}
+
+// issue returns a new nonce for the node, replacing any it did not answer.
+func (c *challenges) issue(id string) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	for pendingID, pending := range c.pending {
+		if now.After(pending.expires) {
+			delete(c.pending, pendingID)
+		}
+	}
+	if _, ok := c.pending[id]; !ok && len(c.pending) >= maxPendingChallenges {
+		return nil, errors.New("too many pending challenges")
+	}
+
+	nonce := newChallenge()
+	c.pending[id] = pendingChallenge{nonce: nonce, expires: now.Add(handshakeTimeout)}
+
+	return nonce, nil
+}
+
+// take returns the nonce issued to the node, which cannot be answered again.
+func (c *challenges) take(id string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pending, ok := c.pending[id]
+	delete(c.pending, id)
+	if !ok || time.Now().After(pending.expires) {
+		return nil, false
+	}
+
+	return pending.nonce, true
+}
+
+// GetChallenge issues the calling peer the nonce to sign its version with.
+func (n *Node) GetChallenge(ctx context.Context, req *proto.ChallengeRequest) (*proto.Challenge, error) {
+	key, ok := peerKey(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "no node key")
+	}
+
+	nonce, err := n.challenges.issue(NodeID(key))
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	return &proto.Challenge{Nonce: nonce}, nil
+}
+
+// authenticate checks that the version of a calling peer answers the
+// challenge we issued it, and returns its node ID.
+func (n *Node) authenticate(ctx context.Context, v *proto.Version) (string, error) {
+	key, ok := peerKey(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no node key")
+	}
+	challenge, ok := n.challenges.take(NodeID(key))
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no pending challenge")
+	}
+	if err := verifyVersion(v, challenge, key); err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	id := NodeID(key)
+	if id == n.ID() {
+		return "", status.Error(codes.InvalidArgument, "connected to self")
+	}
+
+	return id, nil
+}
+
+// verifyListenAddr checks that the peer dialed back answers at the address it
+// claims, with the node ID it authenticated with.
+func (n *Node) verifyListenAddr(ctx context.Context, p *remotePeer) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	_, err := p.client.Ping(ctx, &proto.PingRequest{
+		ListenAddr: n.ListenAddr,
+		Height:     int32(n.chain.Height()),
+	})
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "node %s does not answer at %s", p.id, p.addr)
+	}
+
+	return nil
+}
+
+// verifyRemote checks the version the dialed node answered our challenge
+// with.
+func (n *Node) verifyRemote(remote *peer.Peer, v *proto.Version, challenge []byte) error {
+	key, ok := tlsPeerKey(remote)
+	if !ok {
+		return errors.New("no node key")
+	}
+	if err := verifyVersion(v, challenge, key); err != nil {
+		return err
+	}
+	if NodeID(key) == n.ID() {
+		return errors.New("connected to self")
+	}
+	if n.bans.IsBanned(NodeID(key)) {
+		return errors.New("banned")
+	}
+
+	return nil
+}
+
+func newChallenge() []byte {
+	challenge := make([]byte, challengeLen)
+	if _, err := io.ReadFull(rand.Reader, challenge); err != nil {
+		panic(err)
+	}
+
+	return challenge
+}
+
+// handshakeMessage is what a node signs in its version: the challenge the
+// other node issued it, its key and the address it claims to listen on.
+func handshakeMessage(v *proto.Version, challenge []byte) []byte {
+	msg := []byte(handshakeDomain)
+	msg = append(msg, challenge...)
+	msg = append(msg, v.PublicKey...)
+
+	return append(msg, v.ListenAddr...)
+}
+
+// signVersion signs the version of the node answering challenge.
+func (n *Node) signVersion(v *proto.Version, challenge []byte) {
+	v.PublicKey = n.NodeKey.Public().Bytes()
+	v.Signature = n.NodeKey.Sign(handshakeMessage(v, challenge)).Bytes()
+}
+
+// verifyVersion checks that the version answers challenge and is signed by
+// the key the peer authenticated with over TLS.
+func verifyVersion(v *proto.Version, challenge []byte, key *crypto.PublicKey) error {
+	if len(v.PublicKey) != crypto.PublicKeyLen || len(v.Signature) != crypto.SignatureLen {
+		return errors.New("unsigned version")
+	}
+	if NodeID(key) != hex.EncodeToString(v.PublicKey) {
+		return errors.New("version key differs from the TLS key")
+	}
+
+	signature := crypto.SignatureFromBytes(v.Signature)
+	if !signature.Verify(key, handshakeMessage(v, challenge)) {
+		return errors.New("invalid version signature")
+	}
+
+	return nil
+}
+
+// nodeCertificate returns the TLS certificate of the node key.
+func nodeCertificate(key *crypto.PrivateKey) tls.Certificate {
+	cert, err := crypto.NewTLSCertificate(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return cert
+}
@@ -0,0 +1,159 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func randomNodeKey() []byte {
+	return crypto.GeneratePrivateKey().Public().Bytes()
+}
+
+// listeningNode returns a node serving on a random local port.
+func listeningNode(t *testing.T, cfg ServerConfig) *Node {
+	n := NewNode(cfg)
+	n.ListenAddr = serveNode(t, n)
+
+	return n
+}
+
+func TestLoadNodeKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.key")
+
+	key, err := LoadNodeKey(path)
+	require.Nil(t, err)
+	loaded, err := LoadNodeKey(path)
+	require.Nil(t, err)
+	assert.Equal(t, key.Bytes(), loaded.Bytes())
+}
+
+func TestHandshakeIdentifiesPeersByKey(t *testing.T) {
+	var (
+		a = listeningNode(t, ServerConfig{})
+		b = listeningNode(t, ServerConfig{})
+	)
+
+	p, v, err := a.dialRemoteNode(b.ListenAddr)
+	require.Nil(t, err)
+	a.addPeer(p, v)
+	assert.Equal(t, b.ID(), p.id)
+
+	a.peerLock.RLock()
+	_, ok := a.peers[b.ID()]
+	a.peerLock.RUnlock()
+	assert.True(t, ok)
+
+	b.peerLock.RLock()
+	back, ok := b.peers[a.ID()]
+	b.peerLock.RUnlock()
+	require.True(t, ok)
+	assert.Equal(t, a.ListenAddr, back.addr)
+
+	// the connections are encrypted with the node keys
+	_, err = back.client.Ping(context.Background(), &proto.PingRequest{})
+	require.Nil(t, err)
+}
+
+func TestHandshakeRejectsClaimedAddress(t *testing.T) {
+	var (
+		n       = listeningNode(t, ServerConfig{})
+		victim  = listeningNode(t, ServerConfig{})
+		mallory = listeningNode(t, ServerConfig{})
+	)
+	// mallory claims to listen at the address of the victim
+	mallory.ListenAddr = victim.ListenAddr
+
+	_, _, err := mallory.dialRemoteNode(n.ListenAddr)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Empty(t, n.getPeerList())
+}
+
+func TestHandshakeRejectsReplayedVersion(t *testing.T) {
+	var (
+		n      = listeningNode(t, ServerConfig{})
+		caller = listeningNode(t, ServerConfig{})
+	)
+	p, err := caller.dialPeer(n.ListenAddr, "")
+	require.Nil(t, err)
+	defer p.conn.Close()
+
+	issued, err := p.client.GetChallenge(context.Background(), &proto.ChallengeRequest{})
+	require.Nil(t, err)
+	v := caller.getVersion()
+	v.Challenge = newChallenge()
+	caller.signVersion(v, issued.Nonce)
+	_, err = p.client.Handshake(context.Background(), v)
+	require.Nil(t, err)
+
+	// the challenge was answered, the same version does not handshake again
+	_, err = p.client.Handshake(context.Background(), v)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	issued, err = p.client.GetChallenge(context.Background(), &proto.ChallengeRequest{})
+	require.Nil(t, err)
+	_, err = p.client.Handshake(context.Background(), v)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestChallengesExpire(t *testing.T) {
+	c := newChallenges()
+	nonce, err := c.issue("a")
+	require.Nil(t, err)
+	taken, ok := c.take("a")
+	require.True(t, ok)
+	assert.Equal(t, nonce, taken)
+	_, ok = c.take("a")
+	assert.False(t, ok)
+
+	_, err = c.issue("b")
+	require.Nil(t, err)
+	c.pending["b"] = pendingChallenge{nonce: nonce, expires: time.Now().Add(-time.Second)}
+	_, ok = c.take("b")
+	assert.False(t, ok)
+
+	for i := 0; i < maxPendingChallenges; i++ {
+		_, err = c.issue(fmt.Sprint(i))
+		require.Nil(t, err)
+	}
+	_, err = c.issue("c")
+	assert.NotNil(t, err)
+}
+
+func TestHandshakeRequiresSignature(t *testing.T) {
+	var (
+		n      = listeningNode(t, ServerConfig{})
+		caller = NewNode(ServerConfig{})
+	)
+	p, err := caller.dialPeer(n.ListenAddr, "")
+	require.Nil(t, err)
+	defer p.conn.Close()
+
+	// a version signed by another key than the one of the connection
+	issued, err := p.client.GetChallenge(context.Background(), &proto.ChallengeRequest{})
+	require.Nil(t, err)
+	v := &proto.Version{ListenAddr: "localhost:1", Challenge: newChallenge()}
+	NewNode(ServerConfig{}).signVersion(v, issued.Nonce)
+	_, err = p.client.Handshake(context.Background(), v)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// a version signed over a challenge of our own choosing
+	v = &proto.Version{ListenAddr: "localhost:1", Challenge: newChallenge()}
+	caller.signVersion(v, v.Challenge)
+	_, err = p.client.Handshake(context.Background(), v)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the dialed node has to be the one pinned
+	p, err = caller.dialPeer(n.ListenAddr, NodeID(crypto.GeneratePrivateKey().Public()))
+	require.Nil(t, err)
+	defer p.conn.Close()
+	_, err = p.client.Ping(context.Background(), &proto.PingRequest{})
+	assert.NotNil(t, err)
+}
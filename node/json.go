@@ -0,0 +1,423 @@
+package node
+
+import (
+	"encoding/hex"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+)
+
+// The HTTP gateway encodes hashes, keys, signatures and scripts as hex and
+// addresses in their bech32m form. Field names follow the protobuf ones.
+
+type hexBytes []byte
+
+func (h hexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+func (h *hexBytes) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*h = b
+
+	return nil
+}
+
+type addressBytes []byte
+
+func (a addressBytes) MarshalText() ([]byte, error) {
+	if err := crypto.ValidateAddressBytes(a); err != nil {
+		return nil, err
+	}
+
+	return []byte(crypto.AddressFromBytes(a).String()), nil
+}
+
+func (a *addressBytes) UnmarshalText(text []byte) error {
+	address, err := crypto.ParseAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*a = address.Bytes()
+
+	return nil
+}
+
+type jsonHeader struct {
+	Version   int32    `json:"version"`
+	Height    int32    `json:"height"`
+	PrevHash  hexBytes `json:"prevHash"`
+	RootHash  hexBytes `json:"rootHash"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+type jsonBlock struct {
+	Hash         hexBytes           `json:"hash"`
+	Header       *jsonHeader        `json:"header"`
+	Transactions []*jsonTransaction `json:"transactions"`
+	PublicKey    hexBytes           `json:"publicKey"`
+	Signature    hexBytes           `json:"signature"`
+}
+
+type jsonInputSignature struct {
+	KeyIndex  uint32   `json:"keyIndex"`
+	Signature hexBytes `json:"signature"`
+}
+
+type jsonTxInput struct {
+	PrevTxHash         hexBytes              `json:"prevTxHash"`
+	PrevOutIndex       uint32                `json:"prevOutIndex"`
+	PublicKey          hexBytes              `json:"publicKey,omitempty"`
+	Signature          hexBytes              `json:"signature,omitempty"`
+	MultisigSignatures []*jsonInputSignature `json:"multisigSignatures,omitempty"`
+	UnlockingScript    hexBytes              `json:"unlockingScript,omitempty"`
+	Sequence           uint32                `json:"sequence,omitempty"`
+}
+
+type jsonMultisigLock struct {
+	Threshold  uint32     `json:"threshold"`
+	PublicKeys []hexBytes `json:"publicKeys"`
+}
+
+type jsonTxOutput struct {
+	Amount        int64             `json:"amount"`
+	Address       addressBytes      `json:"address,omitempty"`
+	Multisig      *jsonMultisigLock `json:"multisig,omitempty"`
+	LockingScript hexBytes          `json:"lockingScript,omitempty"`
+	Data          hexBytes          `json:"data,omitempty"`
+	AssetID       hexBytes          `json:"assetId,omitempty"`
+}
+
+type jsonIssuance struct {
+	Amount int64  `json:"amount"`
+	Name   string `json:"name,omitempty"`
+}
+
+type jsonTransaction struct {
+	// Hash is informational, it is ignored when a transaction is submitted.
+	Hash     hexBytes        `json:"hash,omitempty"`
+	Version  int32           `json:"version"`
+	Inputs   []*jsonTxInput  `json:"inputs"`
+	Outputs  []*jsonTxOutput `json:"outputs"`
+	LockTime int64           `json:"lockTime,omitempty"`
+	Issuance *jsonIssuance   `json:"issuance,omitempty"`
+}
+
+// jsonTxInfo locates the transaction only on nodes running the transaction
+// index, the location fields are left out otherwise.
+type jsonTxInfo struct {
+	Transaction   *jsonTransaction `json:"transaction"`
+	BlockHash     hexBytes         `json:"blockHash,omitempty"`
+	Height        *int32           `json:"height,omitempty"`
+	Index         *uint32          `json:"index,omitempty"`
+	Confirmations *int32           `json:"confirmations,omitempty"`
+}
+
+type jsonUTXO struct {
+	TxHash   hexBytes     `json:"txHash"`
+	OutIndex uint32       `json:"outIndex"`
+	Amount   int64        `json:"amount"`
+	AssetID  hexBytes     `json:"assetId,omitempty"`
+	Height   int32        `json:"height"`
+	Address  addressBytes `json:"address,omitempty"`
+}
+
+type jsonTip struct {
+	Height int32       `json:"height"`
+	Hash   hexBytes    `json:"hash"`
+	Header *jsonHeader `json:"header"`
+}
+
+type jsonBlockPage struct {
+	Blocks []*jsonBlock `json:"blocks"`
+	// NextFrom is the height to continue from, omitted on the last page.
+	NextFrom *int32 `json:"nextFrom,omitempty"`
+}
+
+type jsonBalance struct {
+	Address addressBytes `json:"address"`
+	AssetID hexBytes     `json:"assetId,omitempty"`
+	Amount  int64        `json:"amount"`
+}
+
+type jsonMempool struct {
+	Transactions []*jsonTransaction `json:"transactions"`
+	Held         []*jsonTransaction `json:"held"`
+}
+
+type jsonPeer struct {
+	Version    string   `json:"version"`
+	Height     int32    `json:"height"`
+	ListenAddr string   `json:"listenAddr"`
+	NodeID     hexBytes `json:"nodeId"`
+}
+
+type jsonReorg struct {
+	ForkHeight int32    `json:"forkHeight"`
+	OldTip     hexBytes `json:"oldTip"`
+	NewTip     hexBytes `json:"newTip"`
+}
+
+type jsonEvent struct {
+	Type        string           `json:"type"`
+	Height      int32            `json:"height"`
+	Block       *jsonBlock       `json:"block,omitempty"`
+	Transaction *jsonTransaction `json:"transaction,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	Peer        *jsonPeer        `json:"peer,omitempty"`
+	Reorg       *jsonReorg       `json:"reorg,omitempty"`
+}
+
+type jsonHealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type jsonHealth struct {
+	Live   bool               `json:"live"`
+	Ready  bool               `json:"ready"`
+	Checks []*jsonHealthCheck `json:"checks"`
+}
+
+type jsonStatus struct {
+	Version      string   `json:"version"`
+	ChainID      hexBytes `json:"chainId"`
+	Height       int32    `json:"height"`
+	TipHash      hexBytes `json:"tipHash"`
+	BestHeight   int32    `json:"bestHeight"`
+	SyncProgress float64  `json:"syncProgress"`
+	MempoolSize  int32    `json:"mempoolSize"`
+	PeerCount    int32    `json:"peerCount"`
+	jsonHealth
+}
+
+type jsonSubmitted struct {
+	Hash hexBytes `json:"hash"`
+}
+
+func newJSONHeader(header *proto.Header) *jsonHeader {
+	return &jsonHeader{
+		Version:   header.Version,
+		Height:    header.Height,
+		PrevHash:  header.PrevHash,
+		RootHash:  header.RootHash,
+		Timestamp: header.Timestamp,
+	}
+}
+
+func newJSONBlock(block *proto.Block) *jsonBlock {
+	return &jsonBlock{
+		Hash:         types.HashBlock(block),
+		Header:       newJSONHeader(block.Header),
+		Transactions: newJSONTransactions(block.Transactions),
+		PublicKey:    block.PublicKey,
+		Signature:    block.Signature,
+	}
+}
+
+func newJSONTransactions(txx []*proto.Transaction) []*jsonTransaction {
+	j := make([]*jsonTransaction, len(txx))
+	for i, tx := range txx {
+		j[i] = newJSONTransaction(tx)
+	}
+
+	return j
+}
+
+func newJSONTransaction(tx *proto.Transaction) *jsonTransaction {
+	j := &jsonTransaction{
+		Hash:     types.HashTransaction(tx),
+		Version:  tx.Version,
+		Inputs:   make([]*jsonTxInput, len(tx.Inputs)),
+		Outputs:  make([]*jsonTxOutput, len(tx.Outputs)),
+		LockTime: tx.LockTime,
+	}
+	if tx.Issuance != nil {
+		j.Issuance = &jsonIssuance{
+			Amount: tx.Issuance.Amount,
+			Name:   tx.Issuance.Name,
+		}
+	}
+
+	for i, input := range tx.Inputs {
+		j.Inputs[i] = &jsonTxInput{
+			PrevTxHash:      input.PrevTxHash,
+			PrevOutIndex:    input.PrevOutIndex,
+			PublicKey:       input.PublicKey,
+			Signature:       input.Signature,
+			UnlockingScript: input.UnlockingScript,
+			Sequence:        input.Sequence,
+		}
+		for _, signature := range input.MultisigSignatures {
+			j.Inputs[i].MultisigSignatures = append(j.Inputs[i].MultisigSignatures, &jsonInputSignature{
+				KeyIndex:  signature.KeyIndex,
+				Signature: signature.Signature,
+			})
+		}
+	}
+
+	for i, output := range tx.Outputs {
+		j.Outputs[i] = &jsonTxOutput{
+			Amount:        output.Amount,
+			Address:       output.Address,
+			LockingScript: output.LockingScript,
+			Data:          output.Data,
+			AssetID:       output.AssetId,
+		}
+		if output.Multisig != nil {
+			lock := &jsonMultisigLock{Threshold: output.Multisig.Threshold}
+			for _, publicKey := range output.Multisig.PublicKeys {
+				lock.PublicKeys = append(lock.PublicKeys, publicKey)
+			}
+			j.Outputs[i].Multisig = lock
+		}
+	}
+
+	return j
+}
+
+func (j *jsonTransaction) proto() *proto.Transaction {
+	tx := &proto.Transaction{
+		Version:  j.Version,
+		Inputs:   make([]*proto.TxInput, len(j.Inputs)),
+		Outputs:  make([]*proto.TxOutput, len(j.Outputs)),
+		LockTime: j.LockTime,
+	}
+	if j.Issuance != nil {
+		tx.Issuance = &proto.AssetIssuance{
+			Amount: j.Issuance.Amount,
+			Name:   j.Issuance.Name,
+		}
+	}
+
+	for i, input := range j.Inputs {
+		tx.Inputs[i] = &proto.TxInput{
+			PrevTxHash:      input.PrevTxHash,
+			PrevOutIndex:    input.PrevOutIndex,
+			PublicKey:       input.PublicKey,
+			Signature:       input.Signature,
+			UnlockingScript: input.UnlockingScript,
+			Sequence:        input.Sequence,
+		}
+		for _, signature := range input.MultisigSignatures {
+			tx.Inputs[i].MultisigSignatures = append(tx.Inputs[i].MultisigSignatures, &proto.InputSignature{
+				KeyIndex:  signature.KeyIndex,
+				Signature: signature.Signature,
+			})
+		}
+	}
+
+	for i, output := range j.Outputs {
+		tx.Outputs[i] = &proto.TxOutput{
+			Amount:        output.Amount,
+			Address:       output.Address,
+			LockingScript: output.LockingScript,
+			Data:          output.Data,
+			AssetId:       output.AssetID,
+		}
+		if output.Multisig != nil {
+			lock := &proto.MultisigLock{Threshold: output.Multisig.Threshold}
+			for _, publicKey := range output.Multisig.PublicKeys {
+				lock.PublicKeys = append(lock.PublicKeys, publicKey)
+			}
+			tx.Outputs[i].Multisig = lock
+		}
+	}
+
+	return tx
+}
+
+func newJSONUTXO(utxo *proto.UnspentOutput) *jsonUTXO {
+	return &jsonUTXO{
+		TxHash:   utxo.TxHash,
+		OutIndex: utxo.OutIndex,
+		Amount:   utxo.Amount,
+		AssetID:  utxo.AssetId,
+		Height:   utxo.Height,
+		Address:  utxo.Address,
+	}
+}
+
+func newJSONPeer(v *proto.Version) *jsonPeer {
+	return &jsonPeer{
+		Version:    v.Version,
+		Height:     v.Height,
+		ListenAddr: v.ListenAddr,
+		NodeID:     v.PublicKey,
+	}
+}
+
+func newJSONTxInfo(info *proto.TxInfo) *jsonTxInfo {
+	j := &jsonTxInfo{
+		Transaction: newJSONTransaction(info.Transaction),
+	}
+	if len(info.BlockHash) > 0 {
+		j.BlockHash = info.BlockHash
+		j.Height = &info.Height
+		j.Index = &info.Index
+		j.Confirmations = &info.Confirmations
+	}
+
+	return j
+}
+
+func newJSONEvent(ev *proto.Event) *jsonEvent {
+	j := &jsonEvent{
+		Type:   eventTypeName(ev.Type),
+		Height: ev.Height,
+		Error:  ev.Error,
+	}
+	if ev.Block != nil {
+		j.Block = newJSONBlock(ev.Block)
+	}
+	if ev.Transaction != nil {
+		j.Transaction = newJSONTransaction(ev.Transaction)
+	}
+	if ev.Peer != nil {
+		j.Peer = newJSONPeer(ev.Peer)
+	}
+	if ev.Reorg != nil {
+		j.Reorg = &jsonReorg{
+			ForkHeight: ev.Reorg.ForkHeight,
+			OldTip:     ev.Reorg.OldTip,
+			NewTip:     ev.Reorg.NewTip,
+		}
+	}
+
+	return j
+}
+
+func newJSONHealth(live, ready bool, checks []*proto.HealthCheck) jsonHealth {
+	j := jsonHealth{
+		Live:   live,
+		Ready:  ready,
+		Checks: make([]*jsonHealthCheck, len(checks)),
+	}
+	for i, check := range checks {
+		j.Checks[i] = &jsonHealthCheck{
+			Name:   check.Name,
+			OK:     check.Ok,
+			Detail: check.Detail,
+		}
+	}
+
+	return j
+}
+
+func newJSONStatus(status *proto.Status) *jsonStatus {
+	return &jsonStatus{
+		Version:      status.Version,
+		ChainID:      status.ChainId,
+		Height:       status.Height,
+		TipHash:      status.TipHash,
+		BestHeight:   status.BestHeight,
+		SyncProgress: status.SyncProgress,
+		MempoolSize:  status.MempoolSize,
+		PeerCount:    status.PeerCount,
+		jsonHealth:   newJSONHealth(status.Live, status.Ready, status.Checks),
+	}
+}
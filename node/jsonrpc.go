@@ -0,0 +1,361 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"io"
+	"net/http"
+)
+
+// Standard JSON-RPC 2.0 error codes, and the code of errors raised by the
+// methods themselves.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcServerError    = -32000
+
+	maxRPCBodySize = 1 << 20
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func invalidParams(err error) error {
+	return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+}
+
+type rpcMethod func(ctx context.Context, params json.RawMessage) (any, error)
+
+// JSONRPCServer serves JSON-RPC 2.0 over HTTP POST, including batches.
+// Parameters are positional, hashes and keys hex encoded and addresses in
+// their bech32m form, as on the HTTP gateway.
+type JSONRPCServer struct {
+	node    *Node
+	query   *QueryServer
+	methods map[string]rpcMethod
+}
+
+func NewJSONRPCServer(node *Node) *JSONRPCServer {
+	s := &JSONRPCServer{
+		node:  node,
+		query: NewQueryServer(node),
+	}
+	s.methods = map[string]rpcMethod{
+		"chain_getTip":           s.getTip,
+		"chain_getBlockByHeight": s.getBlockByHeight,
+		"chain_getBlockByHash":   s.getBlockByHash,
+		"chain_getTransaction":   s.getTransaction,
+		"tx_send":                s.sendTransaction,
+		"wallet_getBalance":      s.getBalance,
+		"wallet_listUnspent":     s.listUnspent,
+		"net_peers":              s.peers,
+		"node_status":            s.status,
+	}
+
+	return s
+}
+
+func (s *JSONRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.node.isBannedRequest(r) {
+		http.Error(w, "banned", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRPCBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		resp := s.handleMessage(r.Context(), body)
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	responses, errResp := s.handleBatch(r.Context(), body)
+	switch {
+	case errResp != nil:
+		writeJSON(w, http.StatusOK, errResp)
+	case len(responses) == 0:
+		// a batch of notifications only gets no response
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusOK, responses)
+	}
+}
+
+// handleBatch runs the requests of a batch in order. A batch that cannot be
+// parsed is answered by a single error response instead.
+func (s *JSONRPCServer) handleBatch(ctx context.Context, body []byte) ([]*rpcResponse, *rpcResponse) {
+	var messages []json.RawMessage
+	if err := json.Unmarshal(body, &messages); err != nil {
+		return nil, errorResponse(nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+	}
+	if len(messages) == 0 {
+		return nil, errorResponse(nil, &rpcError{Code: rpcInvalidRequest, Message: "empty batch"})
+	}
+
+	var responses []*rpcResponse
+	for _, message := range messages {
+		if resp := s.handleMessage(ctx, message); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+
+	return responses, nil
+}
+
+// handleMessage runs a single request. It returns nil for notifications.
+func (s *JSONRPCServer) handleMessage(ctx context.Context, message []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return errorResponse(nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+		}
+		return errorResponse(nil, &rpcError{Code: rpcInvalidRequest, Message: err.Error()})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, &rpcError{Code: rpcInvalidRequest, Message: "invalid request"})
+	}
+
+	method, ok := s.methods[req.Method]
+	if !ok {
+		if len(req.ID) == 0 {
+			return nil
+		}
+		return errorResponse(req.ID, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %s not found", req.Method)})
+	}
+
+	result, err := method(ctx, req.Params)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr)
+	}
+
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID}
+}
+
+func errorResponse(id json.RawMessage, err *rpcError) *rpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return &rpcResponse{JSONRPC: "2.0", Error: err, ID: id}
+}
+
+// parseParams decodes the positional parameters into args, of which the
+// first required ones must be present.
+func parseParams(params json.RawMessage, required int, args ...any) error {
+	var values []json.RawMessage
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &values); err != nil {
+			return invalidParams(fmt.Errorf("params must be an array"))
+		}
+	}
+	if len(values) < required || len(values) > len(args) {
+		return invalidParams(fmt.Errorf("expected %d to %d params, got %d", required, len(args), len(values)))
+	}
+
+	for i, value := range values {
+		if err := json.Unmarshal(value, args[i]); err != nil {
+			return invalidParams(fmt.Errorf("param %d: %w", i, err))
+		}
+	}
+
+	return nil
+}
+
+func (s *JSONRPCServer) getTip(ctx context.Context, params json.RawMessage) (any, error) {
+	if err := parseParams(params, 0); err != nil {
+		return nil, err
+	}
+
+	tip, err := s.query.GetTip(ctx, &proto.TipRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonTip{
+		Height: tip.Height,
+		Hash:   tip.Hash,
+		Header: newJSONHeader(tip.Header),
+	}, nil
+}
+
+func (s *JSONRPCServer) getBlockByHeight(ctx context.Context, params json.RawMessage) (any, error) {
+	var height int32
+	if err := parseParams(params, 1, &height); err != nil {
+		return nil, err
+	}
+
+	block, err := s.query.GetBlock(ctx, &proto.BlockRequest{Height: height})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJSONBlock(block), nil
+}
+
+func (s *JSONRPCServer) getBlockByHash(ctx context.Context, params json.RawMessage) (any, error) {
+	var hash hexBytes
+	if err := parseParams(params, 1, &hash); err != nil {
+		return nil, err
+	}
+	if len(hash) == 0 {
+		return nil, invalidParams(fmt.Errorf("missing block hash"))
+	}
+
+	block, err := s.query.GetBlock(ctx, &proto.BlockRequest{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJSONBlock(block), nil
+}
+
+func (s *JSONRPCServer) getTransaction(ctx context.Context, params json.RawMessage) (any, error) {
+	var hash hexBytes
+	if err := parseParams(params, 1, &hash); err != nil {
+		return nil, err
+	}
+
+	info, err := s.query.GetTransaction(ctx, &proto.TxRequest{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJSONTxInfo(info), nil
+}
+
+// sendTransaction submits a transaction and returns its hash.
+func (s *JSONRPCServer) sendTransaction(ctx context.Context, params json.RawMessage) (any, error) {
+	var j jsonTransaction
+	if err := parseParams(params, 1, &j); err != nil {
+		return nil, err
+	}
+
+	tx := j.proto()
+	if _, err := s.node.HandleTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	return hexBytes(types.HashTransaction(tx)), nil
+}
+
+func (s *JSONRPCServer) getBalance(ctx context.Context, params json.RawMessage) (any, error) {
+	var (
+		address addressBytes
+		assetID hexBytes
+	)
+	if err := parseParams(params, 1, &address, &assetID); err != nil {
+		return nil, err
+	}
+
+	balance, err := s.node.GetBalance(ctx, &proto.BalanceRequest{Address: address, AssetId: assetID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonBalance{
+		Address: address,
+		AssetID: assetID,
+		Amount:  balance.Amount,
+	}, nil
+}
+
+func (s *JSONRPCServer) listUnspent(ctx context.Context, params json.RawMessage) (any, error) {
+	var address addressBytes
+	if err := parseParams(params, 1, &address); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.node.ListUnspent(ctx, &proto.UnspentRequest{Address: address})
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make([]*jsonUTXO, len(resp.Outputs))
+	for i, utxo := range resp.Outputs {
+		utxos[i] = newJSONUTXO(utxo)
+	}
+
+	return utxos, nil
+}
+
+func (s *JSONRPCServer) peers(ctx context.Context, params json.RawMessage) (any, error) {
+	if err := parseParams(params, 0); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.query.GetPeers(ctx, &proto.PeersRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]*jsonPeer, len(resp.Peers))
+	for i, v := range resp.Peers {
+		peers[i] = newJSONPeer(v)
+	}
+
+	return peers, nil
+}
+
+func (s *JSONRPCServer) status(ctx context.Context, params json.RawMessage) (any, error) {
+	if err := parseParams(params, 0); err != nil {
+		return nil, err
+	}
+
+	status, err := s.query.GetStatus(ctx, &proto.StatusRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJSONStatus(status), nil
+}
@@ -0,0 +1,110 @@
+package node
+
+import (
+	"encoding/json"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testRPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+	ID     json.RawMessage `json:"id"`
+}
+
+func postRPC(t *testing.T, server *httptest.Server, body string) *http.Response {
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	require.Nil(t, err)
+
+	return resp
+}
+
+func callRPC(t *testing.T, server *httptest.Server, body string) *testRPCResponse {
+	resp := postRPC(t, server, body)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var v testRPCResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+
+	return &v
+}
+
+func TestJSONRPCServer(t *testing.T) {
+	var (
+		n      = NewNode(ServerConfig{})
+		server = httptest.NewServer(NewJSONRPCServer(n))
+		god    = crypto.NewPrivateKeyFromSeedString(godSeed).Public().Address()
+	)
+	defer server.Close()
+
+	resp := callRPC(t, server, `{"jsonrpc":"2.0","id":1,"method":"chain_getBlockByHeight","params":[0]}`)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, "1", string(resp.ID))
+	var block jsonBlock
+	require.Nil(t, json.Unmarshal(resp.Result, &block))
+	genesis, err := n.chain.GetBlockByHeight(0)
+	require.Nil(t, err)
+	assert.Equal(t, hexBytes(types.HashBlock(genesis)), block.Hash)
+
+	resp = callRPC(t, server, `{"jsonrpc":"2.0","id":"b","method":"wallet_getBalance","params":["`+god.String()+`"]}`)
+	require.Nil(t, resp.Error)
+	var balance jsonBalance
+	require.Nil(t, json.Unmarshal(resp.Result, &balance))
+	assert.Equal(t, int64(1000), balance.Amount)
+
+	// tx_send takes the transaction in its JSON form
+	tx := spendGenesis(t, n.chain)
+	signInputs(tx)
+	params, err := json.Marshal([]any{newJSONTransaction(tx)})
+	require.Nil(t, err)
+	resp = callRPC(t, server, `{"jsonrpc":"2.0","id":2,"method":"tx_send","params":`+string(params)+`}`)
+	require.Nil(t, resp.Error)
+	var hash hexBytes
+	require.Nil(t, json.Unmarshal(resp.Result, &hash))
+	assert.Equal(t, hexBytes(types.HashTransaction(tx)), hash)
+	assert.True(t, n.mempool.Has(tx))
+
+	// a batch answers each request, skips notifications and keeps errors per
+	// request
+	batch := `[
+		{"jsonrpc":"2.0","id":1,"method":"chain_getTip"},
+		{"jsonrpc":"2.0","method":"net_peers"},
+		{"jsonrpc":"2.0","id":2,"method":"chain_unknown"},
+		{"jsonrpc":"2.0","id":3,"method":"chain_getBlockByHeight","params":["zero"]},
+		{"jsonrpc":"2.0","id":4,"method":"chain_getBlockByHeight","params":[5]},
+		{"jsonrpc":"1.0","id":5,"method":"net_peers"}
+	]`
+	httpResp := postRPC(t, server, batch)
+	defer httpResp.Body.Close()
+	var responses []*testRPCResponse
+	require.Nil(t, json.NewDecoder(httpResp.Body).Decode(&responses))
+	require.Len(t, responses, 5)
+	assert.Nil(t, responses[0].Error)
+	assert.Equal(t, rpcMethodNotFound, responses[1].Error.Code)
+	assert.Equal(t, rpcInvalidParams, responses[2].Error.Code)
+	assert.Equal(t, rpcServerError, responses[3].Error.Code)
+	assert.Equal(t, rpcInvalidRequest, responses[4].Error.Code)
+	assert.Equal(t, "5", string(responses[4].ID))
+
+	resp = callRPC(t, server, `{"jsonrpc":"2.0","id":6,"method":"chain_getBlockByHeight","params":[-1]}`)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, rpcServerError, resp.Error.Code)
+
+	resp = callRPC(t, server, `{"jsonrpc":"2.0","id":1,"method"`)
+	assert.Equal(t, rpcParseError, resp.Error.Code)
+	assert.Equal(t, "null", string(resp.ID))
+
+	resp = callRPC(t, server, `[]`)
+	assert.Equal(t, rpcInvalidRequest, resp.Error.Code)
+
+	httpResp = postRPC(t, server, `[{"jsonrpc":"2.0","method":"net_peers"}]`)
+	httpResp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, httpResp.StatusCode)
+}
@@ -0,0 +1,108 @@
+package node
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"time"
+)
+
+// Subsystems of the node with their own log level.
+const (
+	LogP2P       = "p2p"
+	LogConsensus = "consensus"
+	LogMempool   = "mempool"
+	LogChain     = "chain"
+)
+
+var logSubsystems = []string{LogP2P, LogConsensus, LogMempool, LogChain}
+
+// The mempool logs every received transaction, so it keeps the first
+// logSampleFirst entries with the same message each logSampleTick and then
+// every logSampleThereafter-th.
+const (
+	logSampleTick       = time.Second
+	logSampleFirst      = 10
+	logSampleThereafter = 100
+)
+
+// NewLogger returns the JSON production logger used when ServerConfig.Logger
+// is nil. It lets debug entries through, leaving the filtering to the levels
+// of the subsystems.
+func NewLogger() (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	// the node samples its high volume subsystems itself
+	cfg.Sampling = nil
+
+	return cfg.Build()
+}
+
+// levelCore filters the entries of a core by a level adjustable at runtime.
+// Unlike zapcore.NewIncreaseLevelCore the level may be lowered again later.
+type levelCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return ce
+	}
+
+	return c.Core.Check(entry, ce)
+}
+
+// subsystemLogger derives the logger of a subsystem from the base logger.
+func subsystemLogger(base *zap.Logger, name string, level zap.AtomicLevel) *zap.SugaredLogger {
+	sampled := name == LogMempool
+
+	return base.Named(name).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if sampled {
+			core = zapcore.NewSamplerWithOptions(core, logSampleTick, logSampleFirst, logSampleThereafter)
+		}
+		return &levelCore{Core: core, level: level}
+	})).Sugar()
+}
+
+// LogLevels returns the level of each subsystem.
+func (n *Node) LogLevels() map[string]string {
+	levels := make(map[string]string, len(n.logLevels))
+	for name, level := range n.logLevels {
+		levels[name] = level.String()
+	}
+
+	return levels
+}
+
+// SetLogLevel changes the level of a subsystem, or of all of them when the
+// subsystem is empty.
+func (n *Node) SetLogLevel(subsystem string, level string) error {
+	l, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	if subsystem == "" {
+		for _, level := range n.logLevels {
+			level.SetLevel(l)
+		}
+		return nil
+	}
+
+	atomic, ok := n.logLevels[subsystem]
+	if !ok {
+		return fmt.Errorf("unknown log subsystem %q, expected one of %v", subsystem, logSubsystems)
+	}
+	atomic.SetLevel(l)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestSubsystemLogLevels(t *testing.T) {
+	var (
+		core, logs = observer.New(zapcore.DebugLevel)
+		n          = NewNode(ServerConfig{Logger: zap.New(core)})
+		admin      = NewAdminServer(n)
+	)
+
+	tx := spendGenesis(t, n.chain)
+	signInputs(tx)
+	_, err := n.HandleTransaction(context.Background(), tx)
+	require.Nil(t, err)
+	assert.Equal(t, 0, logs.FilterMessage("Received transaction").Len())
+
+	levels, err := admin.SetLogLevel(context.Background(), &proto.SetLogLevelRequest{Subsystem: LogMempool, Level: "debug"})
+	require.Nil(t, err)
+	assert.Equal(t, "debug", levels.Levels[LogMempool])
+	assert.Equal(t, "info", levels.Levels[LogChain])
+
+	tx = spendGenesis(t, n.chain)
+	signInputs(tx)
+	_, err = n.HandleTransaction(context.Background(), tx)
+	require.Nil(t, err)
+	received := logs.FilterMessage("Received transaction").All()
+	require.Len(t, received, 1)
+	assert.Equal(t, LogMempool, received[0].LoggerName)
+
+	// every subsystem at once
+	levels, err = admin.SetLogLevel(context.Background(), &proto.SetLogLevelRequest{Level: "error"})
+	require.Nil(t, err)
+	for _, name := range logSubsystems {
+		assert.Equal(t, "error", levels.Levels[name])
+	}
+
+	_, err = admin.SetLogLevel(context.Background(), &proto.SetLogLevelRequest{Subsystem: "rpc", Level: "debug"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = admin.SetLogLevel(context.Background(), &proto.SetLogLevelRequest{Subsystem: LogP2P, Level: "loud"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSubsystemLogSampling(t *testing.T) {
+	var (
+		core, logs = observer.New(zapcore.DebugLevel)
+		level      = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+		mempool    = subsystemLogger(zap.New(core), LogMempool, level)
+		chain      = subsystemLogger(zap.New(core), LogChain, level)
+	)
+
+	for i := 0; i < 50; i++ {
+		mempool.Debugw("Received transaction", "i", i)
+		chain.Debugw("Received block", "i", i)
+	}
+
+	assert.Equal(t, logSampleFirst, logs.FilterMessage("Received transaction").Len())
+	assert.Equal(t, 50, logs.FilterMessage("Received block").Len())
+}
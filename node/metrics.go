@@ -0,0 +1,151 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+const metricsNamespace = "blocker"
+
+// metrics of a node. Every node has its own registry, so several nodes can
+// run in one process.
+type metrics struct {
+	registry *prometheus.Registry
+
+	blockProcessing *prometheus.HistogramVec
+	txRejected      *prometheus.CounterVec
+	broadcastErrors *prometheus.CounterVec
+	grpcRequests    *prometheus.HistogramVec
+}
+
+func newMetrics(n *Node) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		blockProcessing: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "block_processing_seconds",
+			Help:      "Time to validate and add a block to the chain.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"result"}),
+		txRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "tx_rejected_total",
+			Help:      "Transactions that failed validation, by reason.",
+		}, []string{"reason"}),
+		broadcastErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "broadcast_errors_total",
+			Help:      "Failed broadcasts to peers, by message.",
+		}, []string{"message"}),
+		grpcRequests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Duration of the gRPC requests served, by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.blockProcessing,
+		m.txRejected,
+		m.broadcastErrors,
+		m.grpcRequests,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "chain_height",
+			Help:      "Height of the chain tip.",
+		}, func() float64 { return float64(n.chain.Height()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "mempool_transactions",
+			Help:      "Transactions waiting for the next block.",
+		}, func() float64 { return float64(n.mempool.Len()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "mempool_held_transactions",
+			Help:      "Timelocked transactions held in the mempool.",
+		}, func() float64 { return float64(n.mempool.HeldLen()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "mempool_bytes",
+			Help:      "Encoded size of all transactions in the mempool.",
+		}, func() float64 { return float64(n.mempool.Bytes()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "peers",
+			Help:      "Connected peers.",
+		}, func() float64 { return float64(len(n.getPeerList())) }),
+	)
+
+	return m
+}
+
+func (m *metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *metrics) observeBlock(start time.Time, err error) {
+	result := "accepted"
+	if err != nil {
+		result = "rejected"
+	}
+
+	m.blockProcessing.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+func (m *metrics) observeRejectedTx(err error) {
+	m.txRejected.WithLabelValues(rejectReason(err)).Inc()
+}
+
+func (m *metrics) observeBroadcastError(msg any) {
+	m.broadcastErrors.WithLabelValues(messageName(msg)).Inc()
+}
+
+// rejectReason maps a validation error to a label of bounded cardinality.
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrNoInputs):
+		return "no_inputs"
+	case errors.Is(err, ErrDoubleSpend):
+		return "double_spend"
+	case errors.Is(err, ErrMissingInput):
+		return "missing_input"
+	case errors.Is(err, ErrInvalidInput):
+		return "invalid_input"
+	case errors.Is(err, ErrInvalidIssuance):
+		return "invalid_issuance"
+	case errors.Is(err, ErrInvalidOutput):
+		return "invalid_output"
+	case errors.Is(err, ErrInsufficientFunds):
+		return "insufficient_funds"
+	case errors.Is(err, ErrLockTooFar):
+		return "lock_too_far"
+	}
+
+	return "other"
+}
+
+func (m *metrics) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.grpcRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+func (m *metrics) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	m.grpcRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+
+	return err
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	n := NewNode(ServerConfig{})
+
+	require.Nil(t, n.addBlock(context.Background(), randomBlock(t, n.chain)))
+	assert.Equal(t, 1, testutil.CollectAndCount(n.metrics.blockProcessing))
+
+	tx := spendGenesis(t, n.chain)
+	signInputs(tx)
+	_, err := n.HandleTransaction(context.Background(), tx)
+	require.Nil(t, err)
+
+	// spending an unknown output is rejected for its missing input
+	missing := spendGenesis(t, n.chain)
+	missing.Inputs[0].PrevTxHash = make([]byte, 32)
+	signInputs(missing)
+	_, err = n.HandleTransaction(context.Background(), missing)
+	require.NotNil(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(n.metrics.txRejected.WithLabelValues("missing_input")))
+
+	// a signature of another key makes the input invalid
+	forged := spendGenesis(t, n.chain)
+	forged.Outputs[0].Amount = 1
+	signature := types.SignTransaction(crypto.GeneratePrivateKey(), forged)
+	forged.Inputs[0].Signature = signature.Bytes()
+	_, err = n.HandleTransaction(context.Background(), forged)
+	require.NotNil(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(n.metrics.txRejected.WithLabelValues("invalid_input")))
+
+	count, err := testutil.GatherAndCount(n.metrics.registry,
+		"blocker_chain_height",
+		"blocker_mempool_transactions",
+		"blocker_mempool_bytes",
+		"blocker_peers")
+	require.Nil(t, err)
+	assert.Equal(t, 4, count)
+
+	families, err := n.metrics.registry.Gather()
+	require.Nil(t, err)
+	values := make(map[string]float64)
+	for _, family := range families {
+		if len(family.Metric) > 0 && family.Metric[0].Gauge != nil {
+			values[family.GetName()] = family.Metric[0].Gauge.GetValue()
+		}
+	}
+	assert.Equal(t, 1.0, values["blocker_chain_height"])
+	assert.Equal(t, 1.0, values["blocker_mempool_transactions"])
+	assert.Greater(t, values["blocker_mempool_bytes"], 0.0)
+	assert.Equal(t, 0.0, values["blocker_peers"])
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/Node/HandleTransaction"}
+	_, err = n.metrics.unaryInterceptor(context.Background(), tx, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(n.metrics.grpcRequests))
+}
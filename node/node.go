@@ -1,29 +1,51 @@
 package node
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/cmkqwerty/blocker/crypto"
 	"github.com/cmkqwerty/blocker/proto"
 	"github.com/cmkqwerty/blocker/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/peer"
+	pb "google.golang.org/protobuf/proto"
 	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const blockTime = 5 * time.Second
+const (
+	blockTime            = 5 * time.Second
+	maxHeadersPerRequest = 500
+	// maxHeldTransactions caps the timelocked transactions the mempool holds.
+	maxHeldTransactions = 1000
+	// maxLockHorizon is how far ahead the lock time of a held transaction may
+	// lie.
+	maxLockHorizon = 24 * time.Hour
+)
 
 type Mempool struct {
 	lock sync.RWMutex
 	txx  map[string]*proto.Transaction
+	// held keeps valid transactions whose timelocks did not expire yet.
+	held map[string]*proto.Transaction
 }
 
 func NewMempool() *Mempool {
 	return &Mempool{
-		txx: make(map[string]*proto.Transaction),
+		txx:  make(map[string]*proto.Transaction),
+		held: make(map[string]*proto.Transaction),
 	}
 }
 
@@ -42,6 +64,36 @@ func (m *Mempool) Clear() []*proto.Transaction {
 	return txx
 }
 
+// Remove drops the transactions, e.g. once a block included them.
+func (m *Mempool) Remove(txx []*proto.Transaction) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, tx := range txx {
+		hash := hex.EncodeToString(types.HashTransaction(tx))
+		delete(m.txx, hash)
+		delete(m.held, hash)
+	}
+}
+
+// Transactions returns the transactions ready for the next block and the held
+// ones without removing them.
+func (m *Mempool) Transactions() ([]*proto.Transaction, []*proto.Transaction) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	txx := make([]*proto.Transaction, 0, len(m.txx))
+	for _, tx := range m.txx {
+		txx = append(txx, tx)
+	}
+	held := make([]*proto.Transaction, 0, len(m.held))
+	for _, tx := range m.held {
+		held = append(held, tx)
+	}
+
+	return txx, held
+}
+
 func (m *Mempool) Len() int {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -49,14 +101,32 @@ func (m *Mempool) Len() int {
 	return len(m.txx)
 }
 
+// Bytes is the encoded size of all transactions in the mempool, held ones
+// included.
+func (m *Mempool) Bytes() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	size := 0
+	for _, tx := range m.txx {
+		size += pb.Size(tx)
+	}
+	for _, tx := range m.held {
+		size += pb.Size(tx)
+	}
+
+	return size
+}
+
 func (m *Mempool) Has(tx *proto.Transaction) bool {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
 	hash := hex.EncodeToString(types.HashTransaction(tx))
 	_, ok := m.txx[hash]
+	_, held := m.held[hash]
 
-	return ok
+	return ok || held
 }
 
 func (m *Mempool) Add(tx *proto.Transaction) bool {
@@ -73,37 +143,229 @@ func (m *Mempool) Add(tx *proto.Transaction) bool {
 	return true
 }
 
+// Hold keeps a transaction that is not final yet out of new blocks until
+// Promote finds it final. It is refused once maxHeldTransactions are held,
+// and when it spends an output a transaction in the mempool already spends.
+func (m *Mempool) Hold(tx *proto.Transaction) bool {
+	if m.Has(tx) {
+		return false
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if len(m.held) >= maxHeldTransactions || m.conflicts(tx) {
+		return false
+	}
+
+	hash := hex.EncodeToString(types.HashTransaction(tx))
+	m.held[hash] = tx
+
+	return true
+}
+
+// conflicts reports whether the transaction spends an output spent by another
+// one in the mempool.
+func (m *Mempool) conflicts(tx *proto.Transaction) bool {
+	spends := make(map[string]bool, len(tx.Inputs))
+	for _, input := range tx.Inputs {
+		spends[utxoKey(input.PrevTxHash, input.PrevOutIndex)] = true
+	}
+
+	for _, pool := range []map[string]*proto.Transaction{m.txx, m.held} {
+		for _, other := range pool {
+			for _, input := range other.Inputs {
+				if spends[utxoKey(input.PrevTxHash, input.PrevOutIndex)] {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *Mempool) HeldLen() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return len(m.held)
+}
+
+// Promote revalidates the held transactions. Final ones become ready for the
+// next block, still premature ones stay held and invalid ones, such as those
+// spending outputs a block spent meanwhile, are dropped.
+func (m *Mempool) Promote(validate func(*proto.Transaction) error) []*proto.Transaction {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var promoted []*proto.Transaction
+	for hash, tx := range m.held {
+		err := validate(tx)
+		if errors.Is(err, ErrTxNotFinal) {
+			continue
+		}
+
+		delete(m.held, hash)
+		if err == nil {
+			m.txx[hash] = tx
+			promoted = append(promoted, tx)
+		}
+	}
+
+	return promoted
+}
+
 type ServerConfig struct {
 	Version    string
 	ListenAddr string
 	PrivateKey *crypto.PrivateKey
+	// NodeKey identifies the node to its peers and secures its connections
+	// with them. A new key is generated when nil, changing the node ID on
+	// every start.
+	NodeKey *crypto.PrivateKey
+	// HTTPListenAddr is where the JSON gateway listens, it is disabled when
+	// empty.
+	HTTPListenAddr string
+	// JSONRPCListenAddr is where the JSON-RPC 2.0 server listens, it is
+	// disabled when empty.
+	JSONRPCListenAddr string
+	// MetricsListenAddr is where the Prometheus metrics are served on
+	// /metrics, next to the /healthz and /readyz probes. They are disabled
+	// when empty.
+	MetricsListenAddr string
+	// TracerProvider receives the spans of the node, tracing is disabled when
+	// nil.
+	TracerProvider trace.TracerProvider
+	// AdminListenAddr is the loopback address the Admin service listens on,
+	// it is disabled when empty. It should only be reachable by the operator
+	// of the node.
+	AdminListenAddr string
+	// Logger is the base logger of the node, NewLogger when nil. Its own level
+	// caps the levels of the subsystems.
+	Logger *zap.Logger
+	// LogLevel is the initial level of every subsystem.
+	LogLevel zapcore.Level
+	// PingInterval is how often peers are pinged, defaultPingInterval when
+	// zero.
+	PingInterval time.Duration
+	// AddrBookPath is where the known nodes are persisted as JSON, they are
+	// kept in memory only when empty.
+	AddrBookPath string
+	// MaxOutbound is how many peers the node dials and keeps connected,
+	// defaultMaxOutbound when zero.
+	MaxOutbound int
+	// MaxInbound is how many peers may dial the node, defaultMaxInbound when
+	// zero.
+	MaxInbound int
+	// BanListPath is where the banned IPs are persisted as JSON, they are
+	// kept in memory only when empty.
+	BanListPath string
+	// BanDuration is how long misbehaving peers are banned,
+	// defaultBanDuration when zero.
+	BanDuration time.Duration
+	// SyncTolerance is how many blocks the node may trail the best known
+	// height and still be ready, defaultSyncTolerance when zero.
+	SyncTolerance int
+	// TxIndex enables the transaction index behind GetTransaction and
+	// GetAddressHistory.
+	TxIndex bool
 }
 
 type Node struct {
 	ServerConfig
-	logger   *zap.SugaredLogger
-	peerLock sync.RWMutex
-	peers    map[proto.NodeClient]*proto.Version
-	mempool  *Mempool
+	logger *zap.SugaredLogger
+	// logLevels holds the adjustable level of each subsystem logger.
+	logLevels       map[string]zap.AtomicLevel
+	p2pLogger       *zap.SugaredLogger
+	consensusLogger *zap.SugaredLogger
+	mempoolLogger   *zap.SugaredLogger
+	chainLogger     *zap.SugaredLogger
+	peerLock        sync.RWMutex
+	// peers are the connected peers by node ID.
+	peers map[string]*remotePeer
+	// reconnecting holds the addresses of lost peers being dialed again.
+	reconnecting map[string]struct{}
+	mempool      *Mempool
+	chain        *Chain
+	events       *EventBus
+	metrics      *metrics
+	scores       *peerScores
+	bans         *BanList
+	challenges   *challenges
+	addrBook     *AddrBook
+	tracer       trace.Tracer
+	// cert is the self-signed TLS certificate of the node key.
+	cert tls.Certificate
+	// blockLock keeps new block events in chain order.
+	blockLock sync.Mutex
+	// lastProduced is when the validator last produced a block, in unix
+	// nanoseconds.
+	lastProduced atomic.Int64
 	proto.UnimplementedNodeServer
 }
 
 func NewNode(cfg ServerConfig) *Node {
-	loggerConfig := zap.NewDevelopmentConfig()
-	loggerConfig.EncoderConfig.TimeKey = ""
-	logger, _ := loggerConfig.Build()
-	return &Node{
-		peers:        make(map[proto.NodeClient]*proto.Version),
+	logger := cfg.Logger
+	if logger == nil {
+		var err error
+		if logger, err = NewLogger(); err != nil {
+			panic(err)
+		}
+	}
+
+	if cfg.NodeKey == nil {
+		cfg.NodeKey = crypto.GeneratePrivateKey()
+	}
+
+	bans, err := NewBanList(cfg.BanListPath)
+	if err != nil {
+		panic(err)
+	}
+	addrBook, err := NewAddrBook(cfg.AddrBookPath)
+	if err != nil {
+		panic(err)
+	}
+
+	chain := NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+	if cfg.TxIndex {
+		if err := chain.EnableTxIndex(NewMemoryTxIndex()); err != nil {
+			panic(err)
+		}
+	}
+
+	n := &Node{
+		peers:        make(map[string]*remotePeer),
+		reconnecting: make(map[string]struct{}),
 		logger:       logger.Sugar(),
 		mempool:      NewMempool(),
+		chain:        chain,
+		events:       NewEventBus(),
+		scores:       newPeerScores(),
+		bans:         bans,
+		challenges:   newChallenges(),
+		addrBook:     addrBook,
+		cert:         nodeCertificate(cfg.NodeKey),
 		ServerConfig: cfg,
 	}
+	n.logLevels = make(map[string]zap.AtomicLevel, len(logSubsystems))
+	for _, name := range logSubsystems {
+		n.logLevels[name] = zap.NewAtomicLevelAt(cfg.LogLevel)
+	}
+	n.p2pLogger = subsystemLogger(logger, LogP2P, n.logLevels[LogP2P])
+	n.consensusLogger = subsystemLogger(logger, LogConsensus, n.logLevels[LogConsensus])
+	n.mempoolLogger = subsystemLogger(logger, LogMempool, n.logLevels[LogMempool])
+	n.chainLogger = subsystemLogger(logger, LogChain, n.logLevels[LogChain])
+	n.metrics = newMetrics(n)
+	n.tracer = n.tracerProvider().Tracer(tracerName)
+
+	return n
 }
 
 func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
 	n.ListenAddr = listenAddr
 	var (
-		opts       []grpc.ServerOption
+		opts       = append(n.grpcServerOptions(), grpc.Creds(n.serverCredentials()))
 		grpcServer = grpc.NewServer(opts...)
 	)
 	ln, err := net.Listen("tcp", listenAddr)
@@ -112,154 +374,671 @@ func (n *Node) Start(listenAddr string, bootstrapNodes []string) error {
 	}
 
 	proto.RegisterNodeServer(grpcServer, n)
+	proto.RegisterQueryServer(grpcServer, NewQueryServer(n))
 
-	n.logger.Infow("Starting node...", "on", n.ListenAddr)
+	n.logger.Infow("Starting node...", "on", n.ListenAddr, "id", n.ID())
 
 	// bootstrap network with the known nodes
 	if len(bootstrapNodes) > 0 {
 		go func() {
 			err := n.bootstrapNetwork(bootstrapNodes)
 			if err != nil {
-				n.logger.Errorw("Bootstrap error", "error", err)
+				n.p2pLogger.Errorw("Bootstrap error", "error", err)
 			}
 		}()
 	}
 
+	go n.heartbeatLoop()
+	go n.connectionLoop()
+
 	if n.PrivateKey != nil {
 		go n.validatorLoop()
 	}
 
+	if n.HTTPListenAddr != "" {
+		go func() {
+			n.logger.Infow("Starting HTTP gateway...", "on", n.HTTPListenAddr)
+			if err := http.ListenAndServe(n.HTTPListenAddr, NewHTTPServer(n)); err != nil {
+				n.logger.Errorw("HTTP gateway error", "error", err)
+			}
+		}()
+	}
+
+	if n.JSONRPCListenAddr != "" {
+		go func() {
+			n.logger.Infow("Starting JSON-RPC server...", "on", n.JSONRPCListenAddr)
+			if err := http.ListenAndServe(n.JSONRPCListenAddr, NewJSONRPCServer(n)); err != nil {
+				n.logger.Errorw("JSON-RPC server error", "error", err)
+			}
+		}()
+	}
+
+	if n.AdminListenAddr != "" {
+		go func() {
+			n.logger.Infow("Starting admin server...", "on", n.AdminListenAddr)
+			if err := n.serveAdmin(n.AdminListenAddr); err != nil {
+				n.logger.Errorw("Admin server error", "error", err)
+			}
+		}()
+	}
+
+	if n.MetricsListenAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", n.metrics.Handler())
+			mux.HandleFunc("/healthz", healthHandler(n, false))
+			mux.HandleFunc("/readyz", healthHandler(n, true))
+
+			n.logger.Infow("Starting metrics server...", "on", n.MetricsListenAddr)
+			if err := http.ListenAndServe(n.MetricsListenAddr, mux); err != nil {
+				n.logger.Errorw("Metrics server error", "error", err)
+			}
+		}()
+	}
+
 	return grpcServer.Serve(ln)
 }
 
+// Handshake connects with the calling peer. The peer signs the challenge we
+// issued it with the node key it authenticated with, and is dialed back at
+// the address it claims only if the same key answers there.
 func (n *Node) Handshake(ctx context.Context, v *proto.Version) (*proto.Version, error) {
-	client, err := makeNodeClient(v.ListenAddr)
+	id, err := n.authenticate(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.acceptInbound(v); err != nil {
+		return nil, err
+	}
+
+	p, err := n.dialPeer(v.ListenAddr, id)
 	if err != nil {
 		return nil, err
 	}
+	if err := n.verifyListenAddr(ctx, p); err != nil {
+		p.conn.Close()
+		return nil, err
+	}
 
-	n.addPeer(client, v)
+	n.addPeer(p, v)
 
-	return n.getVersion(), nil
+	version := n.getVersion()
+	n.signVersion(version, v.Challenge)
+
+	return version, nil
 }
 
-func (n *Node) HandleTransaction(ctx context.Context, tx *proto.Transaction) (*proto.Ack, error) {
-	p, _ := peer.FromContext(ctx)
+func (n *Node) HandleTransaction(ctx context.Context, tx *proto.Transaction) (_ *proto.Ack, err error) {
+	from := "local"
+	if p, ok := peer.FromContext(ctx); ok {
+		from = p.Addr.String()
+	}
 	hash := hex.EncodeToString(types.HashTransaction(tx))
 
-	if n.mempool.Add(tx) {
-		n.logger.Debugw("Received transaction", "from", p.Addr, "hash", hash, "we", n.ListenAddr)
+	ctx, span := n.startSpan(ctx, "HandleTransaction",
+		attribute.String("tx.hash", hash),
+		attribute.String("tx.from", from))
+	defer func() { endSpan(span, err) }()
 
-		go func() {
-			if err := n.broadcast(tx); err != nil {
-				n.logger.Errorw("Broadcast error", "error", err)
-			}
-		}()
+	if err := n.checkRate(ctx); err != nil {
+		return nil, err
+	}
+
+	if n.mempool.Has(tx) {
+		span.SetAttributes(attribute.Bool("tx.known", true))
+		return &proto.Ack{}, nil
+	}
+
+	added := false
+	err = n.validateTransaction(ctx, tx)
+	if errors.Is(err, ErrTxNotFinal) && !n.withinLockHorizon(tx) {
+		err = fmt.Errorf("%w: transaction %s", ErrLockTooFar, hash)
+	}
+	switch {
+	case errors.Is(err, ErrTxNotFinal):
+		added = n.mempool.Hold(tx)
+	case err != nil:
+		n.mempoolLogger.Debugw("Rejected transaction", "from", from, "hash", hash, "error", err)
+		n.metrics.observeRejectedTx(err)
+		n.events.Publish(&proto.Event{
+			Type:        proto.EventType_EVENT_TX_REJECTED,
+			Height:      int32(n.chain.Height()),
+			Transaction: tx,
+			Error:       err.Error(),
+		})
+		n.penalizePeer(ctx, txPenalty(err), "invalid transaction")
+		return nil, err
+	default:
+		added = n.mempool.Add(tx)
+	}
+
+	if added {
+		n.rewardPeer(ctx, rewardTx)
+		n.mempoolLogger.Debugw("Received transaction", "from", from, "hash", hash, "we", n.ListenAddr)
+		n.events.Publish(&proto.Event{
+			Type:        proto.EventType_EVENT_NEW_MEMPOOL_TX,
+			Height:      int32(n.chain.Height()),
+			Transaction: tx,
+		})
+
+		go n.broadcastAsync(detachSpan(ctx), tx)
 	}
 
 	return &proto.Ack{}, nil
 }
 
-func (n *Node) broadcast(msg any) error {
-	for p := range n.peers {
-		switch v := msg.(type) {
-		case *proto.Transaction:
-			_, err := p.HandleTransaction(context.Background(), v)
-			if err != nil {
-				return err
-			}
+func (n *Node) HandleBlock(ctx context.Context, block *proto.Block) (_ *proto.Ack, err error) {
+	hash := types.HashBlock(block)
+
+	ctx, span := n.startSpan(ctx, "HandleBlock",
+		attribute.String("block.hash", hex.EncodeToString(hash)),
+		attribute.Int("block.height", int(block.Header.Height)))
+	defer func() { endSpan(span, err) }()
+
+	if err := n.checkRate(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := n.chain.GetBlockByHash(hash); err == nil {
+		span.SetAttributes(attribute.Bool("block.known", true))
+		return &proto.Ack{}, nil
+	}
+
+	signed := types.VerifyBlock(block)
+
+	tip, err := n.chain.GetBlockByHeight(n.chain.Height())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := n.addBlock(ctx, block); err != nil {
+		n.chainLogger.Debugw("Rejected block", "hash", hex.EncodeToString(hash), "error", err)
+		switch {
+		case !signed:
+			n.penalizePeer(ctx, penaltyBadSignature, "bad block signature")
+		case bytes.Equal(block.Header.PrevHash, types.HashBlock(tip)):
+			// a block on top of our tip can only fail for being invalid,
+			// others may just be ahead of us
+			n.penalizePeer(ctx, penaltyInvalidBlock, "invalid block")
 		}
+		return nil, err
+	}
+	n.rewardPeer(ctx, rewardBlock)
+
+	n.mempool.Remove(block.Transactions)
+	n.mempool.Promote(n.chain.ValidateTransaction)
+
+	n.chainLogger.Debugw("Received block", "height", n.chain.Height(), "hash", hex.EncodeToString(hash), "we", n.ListenAddr)
+
+	go n.broadcastAsync(detachSpan(ctx), block)
+
+	return &proto.Ack{}, nil
+}
+
+// Events returns the bus the node publishes its events on.
+func (n *Node) Events() *EventBus {
+	return n.events
+}
+
+func (n *Node) addBlock(ctx context.Context, block *proto.Block) (err error) {
+	_, span := n.startSpan(ctx, "AddBlock",
+		attribute.Int("block.height", int(block.Header.Height)),
+		attribute.Int("block.transactions", len(block.Transactions)))
+	defer func() { endSpan(span, err) }()
+
+	n.blockLock.Lock()
+	defer n.blockLock.Unlock()
+
+	start := time.Now()
+	err = n.chain.AddBlock(block)
+	n.metrics.observeBlock(start, err)
+	if err != nil {
+		return err
 	}
 
+	n.events.Publish(&proto.Event{
+		Type:   proto.EventType_EVENT_NEW_BLOCK,
+		Height: int32(n.chain.Height()),
+		Block:  block,
+	})
+
 	return nil
 }
 
+func (n *Node) GetTxProof(ctx context.Context, req *proto.TxProofRequest) (*proto.MerkleProof, error) {
+	block, err := n.chain.GetBlockByHash(req.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewTxProof(block, req.TxHash)
+}
+
+func (n *Node) GetAnchor(ctx context.Context, req *proto.AnchorRequest) (*proto.Anchor, error) {
+	return n.chain.GetAnchor(req.PayloadHash)
+}
+
+func (n *Node) GetBalance(ctx context.Context, req *proto.BalanceRequest) (*proto.Balance, error) {
+	amount, err := n.chain.AssetBalance(req.Address, req.AssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.Balance{Amount: amount}, nil
+}
+
+func (n *Node) ListUnspent(ctx context.Context, req *proto.UnspentRequest) (*proto.UnspentResponse, error) {
+	utxos, err := n.chain.ListUnspent(req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &proto.UnspentResponse{
+		Outputs: make([]*proto.UnspentOutput, len(utxos)),
+	}
+	for i, utxo := range utxos {
+		if resp.Outputs[i], err = unspentOutput(utxo); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+func unspentOutput(utxo *UTXO) (*proto.UnspentOutput, error) {
+	txHash, err := hex.DecodeString(utxo.Hash)
+	if err != nil {
+		return nil, err
+	}
+	assetID, err := hex.DecodeString(utxo.AssetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.UnspentOutput{
+		TxHash:   txHash,
+		OutIndex: uint32(utxo.OutIndex),
+		Amount:   utxo.Amount,
+		AssetId:  assetID,
+		Height:   int32(utxo.Height),
+		Address:  utxo.Address,
+	}, nil
+}
+
+func (n *Node) GetTransaction(ctx context.Context, req *proto.TxRequest) (*proto.TxInfo, error) {
+	location, err := n.chain.GetTxLocation(req.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := n.chain.txStore.Get(hex.EncodeToString(req.Hash))
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.TxInfo{
+		Transaction:   tx,
+		BlockHash:     location.BlockHash,
+		Height:        int32(location.Height),
+		Index:         uint32(location.Index),
+		Confirmations: int32(n.chain.Confirmations(location)),
+	}, nil
+}
+
+func (n *Node) GetAddressHistory(ctx context.Context, req *proto.HistoryRequest) (*proto.HistoryResponse, error) {
+	hashes, err := n.chain.AddressHistory(req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.HistoryResponse{TxHashes: hashes}, nil
+}
+
+func (n *Node) GetHeaders(ctx context.Context, req *proto.HeadersRequest) (*proto.HeadersResponse, error) {
+	if req.FromHeight < 0 {
+		return nil, fmt.Errorf("invalid height (%d)", req.FromHeight)
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 || limit > maxHeadersPerRequest {
+		limit = maxHeadersPerRequest
+	}
+
+	resp := &proto.HeadersResponse{}
+	for height := int(req.FromHeight); height <= n.chain.Height() && len(resp.Headers) < limit; height++ {
+		block, err := n.chain.GetBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Headers = append(resp.Headers, &proto.SignedHeader{
+			Header:    block.Header,
+			PublicKey: block.PublicKey,
+			Signature: block.Signature,
+		})
+	}
+
+	return resp, nil
+}
+
+// broadcast sends the message to every peer at once, each within
+// broadcastTimeout. A failing peer does not keep the others from receiving
+// it, the errors of all of them are returned together.
+func (n *Node) broadcast(ctx context.Context, msg any) (err error) {
+	peers := n.getPeers()
+
+	ctx, span := n.startSpan(ctx, "broadcast",
+		attribute.String("message", messageName(msg)),
+		attribute.Int("peers", len(peers)))
+	defer func() { endSpan(span, err) }()
+
+	var (
+		wg      sync.WaitGroup
+		errLock sync.Mutex
+		errs    []error
+	)
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p *remotePeer) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, broadcastTimeout)
+			defer cancel()
+
+			var err error
+			switch v := msg.(type) {
+			case *proto.Transaction:
+				_, err = p.client.HandleTransaction(ctx, v)
+			case *proto.Block:
+				_, err = p.client.HandleBlock(ctx, v)
+			}
+			if err == nil {
+				return
+			}
+
+			if isUnreachable(err) {
+				n.peerFailed(p, err)
+			}
+			errLock.Lock()
+			errs = append(errs, fmt.Errorf("peer %s: %w", p.addr, err))
+			errLock.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// broadcastAsync is broadcast for a goroutine, logging and counting errors.
+func (n *Node) broadcastAsync(ctx context.Context, msg any) {
+	if err := n.broadcast(ctx, msg); err != nil {
+		n.metrics.observeBroadcastError(msg)
+		n.p2pLogger.Errorw("Broadcast error", "error", err)
+	}
+}
+
+// bootstrapNetwork adds the nodes to the address book and dials those we are
+// not connected with yet, as long as outbound slots are free. Nodes that
+// cannot be reached are retried in the background.
 func (n *Node) bootstrapNetwork(bootstrapNodes []string) error {
+	n.learnAddrs(bootstrapNodes...)
+
+	var errs []error
 	for _, node := range bootstrapNodes {
+		if n.countPeers(true) >= n.maxOutbound() {
+			break
+		}
 		if !n.canConnectWith(node) {
 			continue
 		}
-		n.logger.Debugw("Dialing remote nodes...", "ourNode", n.ListenAddr, "remoteNode", node)
+		n.p2pLogger.Debugw("Dialing remote nodes...", "ourNode", n.ListenAddr, "remoteNode", node)
 
-		client, v, err := n.dialRemoteNode(node)
+		p, v, err := n.dialRemoteNode(node)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("dial %s: %w", node, err))
+			go n.reconnect(node)
+			continue
 		}
 
-		n.addPeer(client, v)
+		n.addPeer(p, v)
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func (n *Node) validatorLoop() {
-	n.logger.Infow("Starting validator loop...", "publicKey", n.PrivateKey.Public(), "blockTime", blockTime)
+	n.consensusLogger.Infow("Starting validator loop...", "publicKey", n.PrivateKey.Public(), "blockTime", blockTime)
 	ticker := time.NewTicker(blockTime)
+	n.lastProduced.Store(time.Now().UnixNano())
 
 	for {
 		<-ticker.C
 
-		txx := n.mempool.Clear()
+		n.produceBlock()
+	}
+}
+
+// produceBlock creates a block from the mempool, adds it to our chain and
+// broadcasts it, all under one trace.
+func (n *Node) produceBlock() {
+	ctx, span := n.startSpan(context.Background(), "ProduceBlock")
+	defer span.End()
+
+	txx := n.mempool.Clear()
+
+	n.consensusLogger.Debugw("Creating new block...", "lenTx", len(txx))
+
+	block, err := n.createBlock(ctx, txx)
+	if err == nil {
+		err = n.addBlock(ctx, block)
+	}
+	if err != nil {
+		n.consensusLogger.Errorw("Produce block error", "error", err)
+		endSpan(span, err)
+		// the drained transactions wait for the next block
+		for _, tx := range txx {
+			n.mempool.Add(tx)
+		}
+		return
+	}
+
+	n.lastProduced.Store(time.Now().UnixNano())
+	promoted := n.mempool.Promote(n.chain.ValidateTransaction)
+
+	n.consensusLogger.Debugw("New block created.",
+		"height", n.chain.Height(),
+		"hash", hex.EncodeToString(types.HashBlock(block)),
+		"lenTx", len(block.Transactions),
+		"promotedTx", len(promoted))
+
+	go n.broadcastAsync(ctx, block)
+}
+
+// createBlock builds a block on top of our tip from the transactions that are
+// still valid, skipping any that spend an output already spent in the block.
+func (n *Node) createBlock(ctx context.Context, txx []*proto.Transaction) (*proto.Block, error) {
+	ctx, span := n.startSpan(ctx, "CreateBlock", attribute.Int("mempool.transactions", len(txx)))
+	defer span.End()
+
+	prevBlock, err := n.chain.GetBlockByHeight(n.chain.Height())
+	if err != nil {
+		return nil, err
+	}
+
+	block := &proto.Block{
+		Header: &proto.Header{
+			Version:   1,
+			Height:    int32(n.chain.Height() + 1),
+			PrevHash:  types.HashBlock(prevBlock),
+			Timestamp: time.Now().UnixNano(),
+		},
+		Transactions: []*proto.Transaction{},
+	}
+
+	spent := make(map[string]bool)
+	for _, tx := range txx {
+		if err := n.validateTransaction(ctx, tx); err != nil {
+			if errors.Is(err, ErrTxNotFinal) {
+				n.mempool.Hold(tx)
+			}
+			continue
+		}
+
+		conflict := false
+		for _, input := range tx.Inputs {
+			conflict = conflict || spent[utxoKey(input.PrevTxHash, input.PrevOutIndex)]
+		}
+		if conflict {
+			continue
+		}
+
+		for _, input := range tx.Inputs {
+			spent[utxoKey(input.PrevTxHash, input.PrevOutIndex)] = true
+		}
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	types.SignBlock(n.PrivateKey, block)
+	span.SetAttributes(attribute.Int("block.transactions", len(block.Transactions)))
+
+	return block, nil
+}
 
-		n.logger.Debugw("Creating new block...", "lenTx", len(txx))
+// withinLockHorizon reports whether the lock time of the transaction lies no
+// further than maxLockHorizon ahead of our tip.
+func (n *Node) withinLockHorizon(tx *proto.Transaction) bool {
+	if tx.LockTime < types.LockTimeThreshold {
+		return tx.LockTime <= int64(n.chain.Height())+int64(maxLockHorizon/blockTime)
 	}
+
+	return tx.LockTime <= time.Now().Add(maxLockHorizon).Unix()
 }
 
-func (n *Node) addPeer(client proto.NodeClient, v *proto.Version) {
+func (n *Node) validateTransaction(ctx context.Context, tx *proto.Transaction) error {
+	_, span := n.startSpan(ctx, "ValidateTransaction")
+	defer span.End()
+
+	err := n.chain.ValidateTransaction(tx)
+	switch {
+	case errors.Is(err, ErrTxNotFinal):
+		// a premature transaction is held, not rejected
+		span.SetAttributes(attribute.Bool("tx.final", false))
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// addPeer keeps the peer under its listen address. When we are connected with
+// the address already, only the version is updated and the new connection
+// closed.
+func (n *Node) addPeer(p *remotePeer, v *proto.Version) {
+	// peers share the nodes they are connected with, which we may dial
+	// later on
+	n.learnAddrs(v.PeerList...)
+	n.addrBook.MarkGood(v.ListenAddr)
+
 	n.peerLock.Lock()
 	defer n.peerLock.Unlock()
 
-	n.peers[client] = v
-
-	if len(v.PeerList) > 0 {
-		go func() {
-			go func() {
-				err := n.bootstrapNetwork(v.PeerList)
-				if err != nil {
-					n.logger.Errorw("Bootstrap error", "error", err)
-				}
-			}()
-		}()
+	id := hex.EncodeToString(v.PublicKey)
+	if known, ok := n.peers[id]; ok {
+		if known.addr == v.ListenAddr {
+			known.version = v
+			known.failures = 0
+			if known != p {
+				p.conn.Close()
+			}
+			return
+		}
+		// the node now listens on another address
+		known.conn.Close()
 	}
 
-	n.logger.Debugw("New peer successfully connected.",
+	p.id = id
+	p.addr = v.ListenAddr
+	p.version = v
+	n.peers[id] = p
+	n.events.Publish(&proto.Event{
+		Type:   proto.EventType_EVENT_PEER_CONNECTED,
+		Height: int32(n.chain.Height()),
+		Peer:   v,
+	})
+
+	n.p2pLogger.Debugw("New peer successfully connected.",
 		"ourNode", n.ListenAddr,
 		"remoteNode", v.ListenAddr,
 		"height", v.Height)
 }
 
-func (n *Node) deletePeer(client proto.NodeClient) {
+// deletePeer disconnects the peer. It reports false when the peer was not
+// connected, e.g. because it was removed already.
+func (n *Node) deletePeer(p *remotePeer) bool {
 	n.peerLock.Lock()
 	defer n.peerLock.Unlock()
 
-	delete(n.peers, client)
+	if n.peers[p.id] != p {
+		return false
+	}
+
+	delete(n.peers, p.id)
+	p.conn.Close()
+
+	return true
 }
 
-func (n *Node) dialRemoteNode(addr string) (proto.NodeClient, *proto.Version, error) {
-	client, err := makeNodeClient(addr)
+// dialRemoteNode connects with the node at addr, which has to prove it holds
+// the node key it authenticated with by signing our challenge.
+func (n *Node) dialRemoteNode(addr string) (*remotePeer, *proto.Version, error) {
+	p, err := n.dialPeer(addr, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+
+	var (
+		challenge = newChallenge()
+		version   = n.getVersion()
+		remote    peer.Peer
+	)
+	issued, err := p.client.GetChallenge(ctx, &proto.ChallengeRequest{})
 	if err != nil {
+		p.conn.Close()
+		n.addrBook.MarkFailed(addr)
 		return nil, nil, err
 	}
+	version.Challenge = challenge
+	n.signVersion(version, issued.Nonce)
 
-	v, err := client.Handshake(context.Background(), n.getVersion())
+	v, err := p.client.Handshake(ctx, version, grpc.Peer(&remote))
+	if err == nil {
+		err = n.verifyRemote(&remote, v, challenge)
+	}
 	if err != nil {
+		p.conn.Close()
+		n.addrBook.MarkFailed(addr)
 		return nil, nil, err
 	}
+	p.outbound = true
 
-	return client, v, nil
+	return p, v, nil
 }
 
 func (n *Node) getVersion() *proto.Version {
 	return &proto.Version{
-		Version:    "0.0.1",
-		Height:     0,
+		Version:    n.Version,
+		Height:     int32(n.chain.Height()),
 		ListenAddr: n.ListenAddr,
 		PeerList:   n.getPeerList(),
 	}
 }
 
 func (n *Node) canConnectWith(addr string) bool {
-	if n.ListenAddr == addr {
+	if n.ListenAddr == addr || n.isBannedAddr(addr) {
 		return false
 	}
 
@@ -278,18 +1057,22 @@ func (n *Node) getPeerList() []string {
 	defer n.peerLock.RUnlock()
 
 	var peers []string
-	for _, v := range n.peers {
-		peers = append(peers, v.ListenAddr)
+	for _, p := range n.peers {
+		peers = append(peers, p.addr)
 	}
 
 	return peers
 }
 
-func makeNodeClient(listenAddr string) (proto.NodeClient, error) {
-	conn, err := grpc.Dial(listenAddr, grpc.WithInsecure())
-	if err != nil {
-		return nil, err
+// messageName names the messages exchanged between nodes in logs, metrics and
+// traces.
+func messageName(msg any) string {
+	switch msg.(type) {
+	case *proto.Transaction:
+		return "transaction"
+	case *proto.Block:
+		return "block"
 	}
 
-	return proto.NewNodeClient(conn), nil
+	return "other"
 }
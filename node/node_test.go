@@ -0,0 +1,152 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/cmkqwerty/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMempoolHold(t *testing.T) {
+	var (
+		mempool = NewMempool()
+		ready   = &proto.Transaction{Version: 1}
+		locked  = &proto.Transaction{Version: 1, LockTime: 10}
+		invalid = &proto.Transaction{Version: 1, LockTime: 20}
+	)
+
+	require.True(t, mempool.Add(ready))
+	require.True(t, mempool.Hold(locked))
+	require.True(t, mempool.Hold(invalid))
+	assert.False(t, mempool.Add(locked))
+	assert.True(t, mempool.Has(locked))
+
+	assert.Equal(t, []*proto.Transaction{ready}, mempool.Clear())
+	assert.Equal(t, 2, mempool.HeldLen())
+
+	promoted := mempool.Promote(func(tx *proto.Transaction) error {
+		if tx == invalid {
+			return assert.AnError
+		}
+		return ErrTxNotFinal
+	})
+	assert.Empty(t, promoted)
+	assert.Equal(t, 1, mempool.HeldLen())
+
+	promoted = mempool.Promote(func(tx *proto.Transaction) error {
+		return nil
+	})
+	assert.Equal(t, []*proto.Transaction{locked}, promoted)
+	assert.Equal(t, 0, mempool.HeldLen())
+	assert.Equal(t, []*proto.Transaction{locked}, mempool.Clear())
+}
+
+func TestMempoolHoldLimits(t *testing.T) {
+	mempool := NewMempool()
+	spend := func(prevTxHash []byte, lockTime int64) *proto.Transaction {
+		return &proto.Transaction{
+			Version:  1,
+			LockTime: lockTime,
+			Inputs:   []*proto.TxInput{{PrevTxHash: prevTxHash}},
+		}
+	}
+
+	// transactions spending the same output conflict, the first one is kept
+	prev := util.RandomHash()
+	require.True(t, mempool.Hold(spend(prev, 10)))
+	assert.False(t, mempool.Hold(spend(prev, 11)))
+	require.True(t, mempool.Add(spend(util.RandomHash(), 0)))
+	_, pending := mempool.Transactions()
+	assert.Len(t, pending, 1)
+
+	for mempool.HeldLen() < maxHeldTransactions {
+		require.True(t, mempool.Hold(spend(util.RandomHash(), 10)))
+	}
+	assert.False(t, mempool.Hold(spend(util.RandomHash(), 10)))
+}
+
+func TestHandleTimelockedTransaction(t *testing.T) {
+	n := NewNode(ServerConfig{})
+
+	// lock times beyond the horizon are refused, by height and by time
+	tx := spendGenesis(t, n.chain)
+	tx.LockTime = int64(maxLockHorizon/blockTime) + 1
+	signInputs(tx)
+	_, err := n.HandleTransaction(context.Background(), tx)
+	assert.ErrorIs(t, err, ErrLockTooFar)
+
+	tx.LockTime = time.Now().Add(2 * maxLockHorizon).Unix()
+	signInputs(tx)
+	_, err = n.HandleTransaction(context.Background(), tx)
+	assert.ErrorIs(t, err, ErrLockTooFar)
+	assert.Equal(t, 0, n.mempool.HeldLen())
+
+	locked := spendGenesis(t, n.chain)
+	locked.LockTime = 10
+	signInputs(locked)
+	_, err = n.HandleTransaction(context.Background(), locked)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n.mempool.HeldLen())
+
+	// a block spending the same output drops the held transaction
+	spent := spendGenesis(t, n.chain)
+	signInputs(spent)
+	block := randomBlock(t, n.chain)
+	block.Transactions = append(block.Transactions, spent)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	_, err = n.HandleBlock(context.Background(), block)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n.mempool.HeldLen())
+}
+
+func TestProduceBlockKeepsTransactionsOnFailure(t *testing.T) {
+	n := NewNode(ServerConfig{PrivateKey: crypto.GeneratePrivateKey()})
+	tx := spendGenesis(t, n.chain)
+	signInputs(tx)
+	require.True(t, n.mempool.Add(tx))
+
+	// a tip from the future makes the block we produce now invalid
+	block := randomBlock(t, n.chain)
+	block.Header.Timestamp = time.Now().Add(maxBlockTimeDrift / 2).UnixNano()
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, n.chain.AddBlock(block))
+
+	n.produceBlock()
+	assert.Equal(t, 1, n.chain.Height())
+	assert.True(t, n.mempool.Has(tx))
+}
+
+func TestHandleBlock(t *testing.T) {
+	var (
+		n  = NewNode(ServerConfig{})
+		tx = spendGenesis(t, n.chain)
+	)
+	signInputs(tx)
+	require.True(t, n.mempool.Add(tx))
+
+	block := randomBlock(t, n.chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+
+	_, err := n.HandleBlock(context.Background(), block)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n.chain.Height())
+	assert.Equal(t, 0, n.mempool.Len())
+
+	// blocks we already have are acknowledged again without being added
+	_, err = n.HandleBlock(context.Background(), block)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n.chain.Height())
+
+	// a block that does not extend our tip is rejected
+	_, err = n.HandleBlock(context.Background(), randomBlock(t, NewChain(NewMemoryBlockStore(), NewMemoryTXStore())))
+	assert.NotNil(t, err)
+
+	_, err = n.HandleBlock(context.Background(), util.RandomBlock())
+	assert.NotNil(t, err)
+}
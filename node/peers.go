@@ -0,0 +1,370 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPingInterval = 5 * time.Second
+	pingTimeout         = 2 * time.Second
+	// maxPeerFailures consecutive failed pings or broadcasts remove a peer.
+	maxPeerFailures = 3
+
+	broadcastTimeout = 2 * time.Second
+	handshakeTimeout = 5 * time.Second
+
+	reconnectBaseDelay   = time.Second
+	reconnectMaxDelay    = time.Minute
+	maxReconnectAttempts = 8
+
+	defaultMaxOutbound = 8
+	defaultMaxInbound  = 16
+	// connectInterval is how often free outbound slots are filled from the
+	// address book.
+	connectInterval = 10 * time.Second
+	// addrExchangeInterval is how often peers are asked for the nodes they
+	// know.
+	addrExchangeInterval = time.Minute
+	maxAddrsPerReply     = 100
+)
+
+// remotePeer is a connected peer. Its version and failures are guarded by the
+// peer lock of the node.
+type remotePeer struct {
+	// id is the node ID the peer authenticated with.
+	id     string
+	addr   string
+	conn   *grpc.ClientConn
+	client proto.NodeClient
+	// outbound peers were dialed by us, the others dialed us.
+	outbound bool
+	version  *proto.Version
+	failures int
+}
+
+// dialPeer connects to the node at listenAddr. With an ID only the node with
+// that ID is accepted there.
+func (n *Node) dialPeer(listenAddr string, id string) (*remotePeer, error) {
+	conn, err := grpc.Dial(listenAddr, n.grpcDialOptions(id)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &remotePeer{
+		id:     id,
+		addr:   listenAddr,
+		conn:   conn,
+		client: proto.NewNodeClient(conn),
+	}, nil
+}
+
+// getPeers returns the connected peers.
+func (n *Node) getPeers() []*remotePeer {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	peers := make([]*remotePeer, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+
+	return peers
+}
+
+// getPeerVersions returns what the connected peers told about themselves.
+func (n *Node) getPeerVersions() []*proto.Version {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	versions := make([]*proto.Version, 0, len(n.peers))
+	for _, p := range n.peers {
+		versions = append(versions, p.version)
+	}
+
+	return versions
+}
+
+func (n *Node) pingInterval() time.Duration {
+	if n.PingInterval > 0 {
+		return n.PingInterval
+	}
+
+	return defaultPingInterval
+}
+
+func (n *Node) Ping(ctx context.Context, req *proto.PingRequest) (*proto.Pong, error) {
+	if key, ok := peerKey(ctx); ok {
+		n.peerLock.RLock()
+		p, ok := n.peers[NodeID(key)]
+		n.peerLock.RUnlock()
+		if ok {
+			n.peerAlive(p, req.Height)
+		}
+	}
+
+	return &proto.Pong{Height: int32(n.chain.Height())}, nil
+}
+
+func (n *Node) heartbeatLoop() {
+	ticker := time.NewTicker(n.pingInterval())
+
+	for {
+		<-ticker.C
+
+		n.pingPeers()
+	}
+}
+
+// pingPeers pings every peer at once and waits for the answers.
+func (n *Node) pingPeers() {
+	var wg sync.WaitGroup
+	for _, p := range n.getPeers() {
+		wg.Add(1)
+		go func(p *remotePeer) {
+			defer wg.Done()
+			n.pingPeer(p)
+		}(p)
+	}
+
+	wg.Wait()
+}
+
+func (n *Node) pingPeer(p *remotePeer) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	pong, err := p.client.Ping(ctx, &proto.PingRequest{
+		ListenAddr: n.ListenAddr,
+		Height:     int32(n.chain.Height()),
+	})
+	if err != nil {
+		n.peerFailed(p, err)
+		return
+	}
+
+	n.peerAlive(p, pong.Height)
+}
+
+// peerAlive resets the failures of the peer and records its height.
+func (n *Node) peerAlive(p *remotePeer, height int32) {
+	n.peerLock.Lock()
+	p.failures = 0
+	if p.version != nil && p.version.Height != height {
+		// the version may still be read by others, so it is replaced
+		v := pb.Clone(p.version).(*proto.Version)
+		v.Height = height
+		p.version = v
+	}
+	n.peerLock.Unlock()
+
+	n.addrBook.MarkGood(p.addr)
+}
+
+// peerFailed counts a failure to reach the peer. After maxPeerFailures in a row
+// the peer is removed and dialed again later.
+func (n *Node) peerFailed(p *remotePeer, err error) {
+	n.peerLock.Lock()
+	p.failures++
+	failures := p.failures
+	n.peerLock.Unlock()
+
+	n.p2pLogger.Debugw("Peer unreachable", "remoteNode", p.addr, "failures", failures, "error", err)
+
+	if failures >= maxPeerFailures && n.deletePeer(p) {
+		n.p2pLogger.Infow("Removed dead peer", "remoteNode", p.addr, "failures", failures)
+		go n.reconnect(p.addr)
+	}
+}
+
+// isUnreachable tells transport failures apart from errors the peer answered
+// with, e.g. when it rejects a transaction.
+func isUnreachable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+
+	return false
+}
+
+// reconnectDelay is how long to wait before the attempt-th reconnect, doubling
+// from reconnectBaseDelay up to reconnectMaxDelay.
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectBaseDelay << attempt
+	if delay <= 0 || delay > reconnectMaxDelay {
+		return reconnectMaxDelay
+	}
+
+	return delay
+}
+
+// reconnect dials a lost peer again with backoff until it is connected or
+// maxReconnectAttempts failed. Only one reconnect runs per address.
+func (n *Node) reconnect(addr string) {
+	n.peerLock.Lock()
+	if _, ok := n.reconnecting[addr]; ok {
+		n.peerLock.Unlock()
+		return
+	}
+	n.reconnecting[addr] = struct{}{}
+	n.peerLock.Unlock()
+
+	defer func() {
+		n.peerLock.Lock()
+		delete(n.reconnecting, addr)
+		n.peerLock.Unlock()
+	}()
+
+	for attempt := 0; attempt < maxReconnectAttempts; attempt++ {
+		time.Sleep(reconnectDelay(attempt))
+
+		if !n.canConnectWith(addr) || n.countPeers(true) >= n.maxOutbound() {
+			// connected again meanwhile, or to enough others
+			return
+		}
+
+		p, v, err := n.dialRemoteNode(addr)
+		if err == nil {
+			n.addPeer(p, v)
+			return
+		}
+
+		n.p2pLogger.Debugw("Reconnect failed", "remoteNode", addr, "attempt", attempt+1, "error", err)
+	}
+
+	n.p2pLogger.Infow("Gave up reconnecting", "remoteNode", addr, "attempts", maxReconnectAttempts)
+}
+
+func (n *Node) maxOutbound() int {
+	if n.MaxOutbound > 0 {
+		return n.MaxOutbound
+	}
+
+	return defaultMaxOutbound
+}
+
+func (n *Node) maxInbound() int {
+	if n.MaxInbound > 0 {
+		return n.MaxInbound
+	}
+
+	return defaultMaxInbound
+}
+
+// countPeers counts the outbound or the inbound peers.
+func (n *Node) countPeers(outbound bool) int {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	count := 0
+	for _, p := range n.peers {
+		if p.outbound == outbound {
+			count++
+		}
+	}
+
+	return count
+}
+
+// acceptInbound refuses new peers dialing us once maxInbound are connected.
+// Their addresses are kept to dial them later if outbound slots free up.
+func (n *Node) acceptInbound(v *proto.Version) error {
+	if n.countPeers(false) < n.maxInbound() || !n.canConnectWith(v.ListenAddr) {
+		return nil
+	}
+
+	n.learnAddrs(v.PeerList...)
+	n.learnAddrs(v.ListenAddr)
+
+	return status.Error(codes.ResourceExhausted, "too many inbound peers")
+}
+
+// learnAddrs adds the addresses of other nodes to the address book.
+func (n *Node) learnAddrs(addrs ...string) {
+	learned := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr != n.ListenAddr {
+			learned = append(learned, addr)
+		}
+	}
+
+	n.addrBook.Add(learned...)
+}
+
+func (n *Node) GetAddrs(ctx context.Context, req *proto.GetAddrsRequest) (*proto.Addrs, error) {
+	max := int(req.Max)
+	if max <= 0 || max > maxAddrsPerReply {
+		max = maxAddrsPerReply
+	}
+
+	return &proto.Addrs{Addrs: n.addrBook.Addrs(max)}, nil
+}
+
+func (n *Node) connectionLoop() {
+	var (
+		connect  = time.NewTicker(connectInterval)
+		exchange = time.NewTicker(addrExchangeInterval)
+	)
+
+	for {
+		select {
+		case <-connect.C:
+			n.fillOutbound()
+			if err := n.addrBook.Save(); err != nil {
+				n.p2pLogger.Errorw("Address book error", "error", err)
+			}
+		case <-exchange.C:
+			n.exchangeAddrs()
+		}
+	}
+}
+
+// fillOutbound dials nodes from the address book until the outbound slots are
+// taken, spreading the peers across subnets.
+func (n *Node) fillOutbound() {
+	missing := n.maxOutbound() - n.countPeers(true)
+	if missing <= 0 {
+		return
+	}
+
+	groups := make(map[string]int)
+	for _, p := range n.getPeers() {
+		if p.outbound {
+			groups[addrGroup(p.addr)]++
+		}
+	}
+
+	for _, addr := range n.addrBook.Select(missing, groups, n.canConnectWith) {
+		n.p2pLogger.Debugw("Dialing remote nodes...", "ourNode", n.ListenAddr, "remoteNode", addr)
+
+		p, v, err := n.dialRemoteNode(addr)
+		if err != nil {
+			n.p2pLogger.Debugw("Dial failed", "remoteNode", addr, "error", err)
+			continue
+		}
+
+		n.addPeer(p, v)
+	}
+}
+
+// exchangeAddrs asks the peers for the nodes they know.
+func (n *Node) exchangeAddrs() {
+	for _, p := range n.getPeers() {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		resp, err := p.client.GetAddrs(ctx, &proto.GetAddrsRequest{Max: maxAddrsPerReply})
+		cancel()
+		if err != nil {
+			n.p2pLogger.Debugw("Address exchange failed", "remoteNode", p.addr, "error", err)
+			continue
+		}
+
+		n.learnAddrs(resp.Addrs...)
+	}
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveNode serves the Node service of n on a random local port until the
+// test ends and returns its address.
+func serveNode(t *testing.T, n *Node) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	server := grpc.NewServer(append(n.grpcServerOptions(), grpc.Creds(n.serverCredentials()))...)
+	proto.RegisterNodeServer(server, n)
+	proto.RegisterQueryServer(server, NewQueryServer(n))
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+
+	return ln.Addr().String()
+}
+
+func connect(t *testing.T, n *Node, addr string) *remotePeer {
+	p, err := n.dialPeer(addr, "")
+	require.Nil(t, err)
+	n.addPeer(p, &proto.Version{ListenAddr: addr, PublicKey: randomNodeKey()})
+
+	return p
+}
+
+func TestBroadcastPastFailingPeer(t *testing.T) {
+	var (
+		sender   = NewNode(ServerConfig{})
+		receiver = NewNode(ServerConfig{})
+		dead     = connect(t, sender, "localhost:1")
+	)
+	connect(t, sender, serveNode(t, receiver))
+
+	tx := spendGenesis(t, sender.chain)
+	signInputs(tx)
+
+	err := sender.broadcast(context.Background(), tx)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "peer localhost:1")
+	assert.True(t, receiver.mempool.Has(tx))
+	assert.Equal(t, 1, dead.failures)
+}
+
+func TestPingRemovesDeadPeer(t *testing.T) {
+	var (
+		n        = NewNode(ServerConfig{})
+		receiver = NewNode(ServerConfig{})
+		addr     = serveNode(t, receiver)
+	)
+	require.Nil(t, receiver.addBlock(context.Background(), randomBlock(t, receiver.chain)))
+	connect(t, n, addr)
+	connect(t, n, "localhost:1")
+
+	for i := 0; i < maxPeerFailures-1; i++ {
+		n.pingPeers()
+	}
+	assert.Len(t, n.getPeerList(), 2)
+	assert.Equal(t, 1, n.BestHeight())
+
+	n.pingPeers()
+	assert.Equal(t, []string{addr}, n.getPeerList())
+
+	// the answering peer told its height
+	versions := n.getPeerVersions()
+	require.Len(t, versions, 1)
+	assert.Equal(t, int32(1), versions[0].Height)
+}
+
+func TestReconnectDelay(t *testing.T) {
+	assert.Equal(t, time.Second, reconnectDelay(0))
+	assert.Equal(t, 4*time.Second, reconnectDelay(2))
+	assert.Equal(t, reconnectMaxDelay, reconnectDelay(6))
+	assert.Equal(t, reconnectMaxDelay, reconnectDelay(100))
+}
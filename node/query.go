@@ -0,0 +1,111 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+)
+
+// QueryServer serves the read-only Query service from the chain, mempool and
+// peers of the node.
+type QueryServer struct {
+	node *Node
+	proto.UnimplementedQueryServer
+}
+
+func NewQueryServer(node *Node) *QueryServer {
+	return &QueryServer{node: node}
+}
+
+func (q *QueryServer) GetBlock(ctx context.Context, req *proto.BlockRequest) (*proto.Block, error) {
+	return q.getBlock(req)
+}
+
+func (q *QueryServer) GetHeader(ctx context.Context, req *proto.BlockRequest) (*proto.Header, error) {
+	block, err := q.getBlock(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Header, nil
+}
+
+func (q *QueryServer) getBlock(req *proto.BlockRequest) (*proto.Block, error) {
+	if len(req.Hash) > 0 {
+		return q.node.chain.GetBlockByHash(req.Hash)
+	}
+
+	return q.node.chain.GetBlockByHeight(int(req.Height))
+}
+
+// GetTransaction returns a confirmed transaction. Its location is only filled
+// in when the node runs the transaction index.
+func (q *QueryServer) GetTransaction(ctx context.Context, req *proto.TxRequest) (*proto.TxInfo, error) {
+	tx, err := q.node.chain.txStore.Get(hex.EncodeToString(req.Hash))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &proto.TxInfo{Transaction: tx}
+
+	location, err := q.node.chain.GetTxLocation(req.Hash)
+	switch {
+	case errors.Is(err, ErrTxIndexDisabled):
+	case err != nil:
+		return nil, err
+	default:
+		info.BlockHash = location.BlockHash
+		info.Height = int32(location.Height)
+		info.Index = uint32(location.Index)
+		info.Confirmations = int32(q.node.chain.Confirmations(location))
+	}
+
+	return info, nil
+}
+
+func (q *QueryServer) GetTip(ctx context.Context, req *proto.TipRequest) (*proto.Tip, error) {
+	block, err := q.node.chain.GetBlockByHeight(q.node.chain.Height())
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.Tip{
+		Height: int32(q.node.chain.Height()),
+		Hash:   types.HashBlock(block),
+		Header: block.Header,
+	}, nil
+}
+
+func (q *QueryServer) GetUTXO(ctx context.Context, req *proto.UTXORequest) (*proto.UnspentOutput, error) {
+	utxo, err := q.node.chain.GetUTXO(req.TxHash, req.OutIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return unspentOutput(utxo)
+}
+
+func (q *QueryServer) GetMempool(ctx context.Context, req *proto.MempoolRequest) (*proto.MempoolResponse, error) {
+	txx, held := q.node.mempool.Transactions()
+
+	return &proto.MempoolResponse{
+		Transactions: txx,
+		Held:         held,
+	}, nil
+}
+
+func (q *QueryServer) GetPeers(ctx context.Context, req *proto.PeersRequest) (*proto.PeersResponse, error) {
+	return &proto.PeersResponse{Peers: q.node.getPeerVersions()}, nil
+}
+
+// Subscribe streams the events matching the request, after replaying the
+// blocks from the requested height.
+func (q *QueryServer) Subscribe(req *proto.SubscribeRequest, stream proto.Query_SubscribeServer) error {
+	return q.node.streamEvents(stream.Context(), req, stream.Send)
+}
+
+func (q *QueryServer) GetStatus(ctx context.Context, req *proto.StatusRequest) (*proto.Status, error) {
+	return q.node.Status()
+}
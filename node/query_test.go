@@ -0,0 +1,75 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestQueryServer(t *testing.T) {
+	var (
+		ctx = context.Background()
+		n   = NewNode(ServerConfig{})
+		q   = NewQueryServer(n)
+		tx  = spendGenesis(t, n.chain)
+	)
+	signInputs(tx)
+
+	block := randomBlock(t, n.chain)
+	block.Transactions = append(block.Transactions, tx)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, n.chain.AddBlock(block))
+
+	tip, err := q.GetTip(ctx, &proto.TipRequest{})
+	require.Nil(t, err)
+	assert.Equal(t, int32(1), tip.Height)
+	assert.Equal(t, types.HashBlock(block), tip.Hash)
+
+	byHeight, err := q.GetBlock(ctx, &proto.BlockRequest{Height: 1})
+	require.Nil(t, err)
+	byHash, err := q.GetBlock(ctx, &proto.BlockRequest{Hash: tip.Hash})
+	require.Nil(t, err)
+	assert.Equal(t, byHeight, byHash)
+
+	header, err := q.GetHeader(ctx, &proto.BlockRequest{Height: 0})
+	require.Nil(t, err)
+	assert.Equal(t, int32(0), header.Height)
+
+	_, err = q.GetBlock(ctx, &proto.BlockRequest{Height: 2})
+	assert.NotNil(t, err)
+	_, err = q.GetBlock(ctx, &proto.BlockRequest{Height: -1})
+	assert.NotNil(t, err)
+	_, err = q.GetHeader(ctx, &proto.BlockRequest{Height: -1})
+	assert.NotNil(t, err)
+
+	txHash := types.HashTransaction(tx)
+	info, err := q.GetTransaction(ctx, &proto.TxRequest{Hash: txHash})
+	require.Nil(t, err)
+	assert.Equal(t, txHash, types.HashTransaction(info.Transaction))
+	assert.Nil(t, info.BlockHash)
+
+	utxo, err := q.GetUTXO(ctx, &proto.UTXORequest{TxHash: txHash, OutIndex: 0})
+	require.Nil(t, err)
+	assert.Equal(t, tx.Outputs[0].Amount, utxo.Amount)
+	assert.Equal(t, tx.Outputs[0].Address, utxo.Address)
+
+	_, err = q.GetUTXO(ctx, &proto.UTXORequest{TxHash: tx.Inputs[0].PrevTxHash, OutIndex: 0})
+	assert.NotNil(t, err)
+
+	pending := &proto.Transaction{Version: 1}
+	held := &proto.Transaction{Version: 1, LockTime: 10}
+	n.mempool.Add(pending)
+	n.mempool.Hold(held)
+	mempool, err := q.GetMempool(ctx, &proto.MempoolRequest{})
+	require.Nil(t, err)
+	assert.Equal(t, []*proto.Transaction{pending}, mempool.Transactions)
+	assert.Equal(t, []*proto.Transaction{held}, mempool.Held)
+
+	peers, err := q.GetPeers(ctx, &proto.PeersRequest{})
+	require.Nil(t, err)
+	assert.Empty(t, peers.Peers)
+}
@@ -0,0 +1,332 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"github.com/cmkqwerty/blocker/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scores of peers start at 0 and are capped at maxScore. A peer whose score
+// drops to banScore is disconnected and banned.
+const (
+	maxScore = 100
+	banScore = -100
+
+	defaultBanDuration = 24 * time.Hour
+)
+
+// Points a peer gains for useful data and loses for violations.
+const (
+	rewardTx    = 1
+	rewardBlock = 5
+
+	penaltyBadSignature = 50
+	penaltyInvalidBlock = 50
+	penaltyInvalidTx    = 10
+	// penaltyConflictingTx is small, honest peers may relay a transaction
+	// that lost a race against another spending the same output.
+	penaltyConflictingTx = 2
+	penaltySpam          = 20
+)
+
+// A peer sending more than maxMessagesPerSecond transactions and blocks spams.
+const maxMessagesPerSecond = 200
+
+type peerScore struct {
+	score int
+	// window and messages count the messages of the current second.
+	window   time.Time
+	messages int
+}
+
+// peerScores tracks the behaviour of peers by node ID, and of callers that did
+// not handshake by IP.
+type peerScores struct {
+	lock   sync.Mutex
+	scores map[string]*peerScore
+}
+
+func newPeerScores() *peerScores {
+	return &peerScores{
+		scores: make(map[string]*peerScore),
+	}
+}
+
+func (s *peerScores) get(id string) *peerScore {
+	score, ok := s.scores[id]
+	if !ok {
+		score = &peerScore{}
+		s.scores[id] = score
+	}
+
+	return score
+}
+
+// add changes the score of the peer and returns the new one.
+func (s *peerScores) add(id string, points int) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	score := s.get(id)
+	score.score += points
+	if score.score > maxScore {
+		score.score = maxScore
+	}
+
+	return score.score
+}
+
+// message counts a message of the peer and reports whether it stays within
+// maxMessagesPerSecond.
+func (s *peerScores) message(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	score := s.get(id)
+	now := time.Now()
+	if now.Sub(score.window) >= time.Second {
+		score.window = now
+		score.messages = 0
+	}
+	score.messages++
+
+	return score.messages <= maxMessagesPerSecond
+}
+
+func (s *peerScores) reset(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.scores, id)
+}
+
+func (s *peerScores) all() map[string]int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	scores := make(map[string]int32, len(s.scores))
+	for id, score := range s.scores {
+		scores[id] = int32(score.score)
+	}
+
+	return scores
+}
+
+// txPenalty weighs the reason a transaction of a peer was rejected for.
+func txPenalty(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		// a bad signature or unlocking script
+		return penaltyBadSignature
+	case errors.Is(err, ErrMissingInput), errors.Is(err, ErrTxNotFinal), errors.Is(err, ErrLockTooFar):
+		// honest peers relay chains of transactions and timelocked ones we
+		// may not be able to accept yet
+		return 0
+	case errors.Is(err, ErrDoubleSpend):
+		return penaltyConflictingTx
+	}
+
+	return penaltyInvalidTx
+}
+
+// remoteIP returns the IP of the caller, false for local calls such as the
+// HTTP gateway.
+func remoteIP(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "", false
+	}
+
+	return host, true
+}
+
+// hostIPs resolves the host of a listen address.
+func hostIPs(addr string) []string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+
+	ips, _ := net.LookupHost(host)
+
+	return ips
+}
+
+func (n *Node) banDuration() time.Duration {
+	if n.BanDuration > 0 {
+		return n.BanDuration
+	}
+
+	return defaultBanDuration
+}
+
+func (n *Node) isBannedAddr(addr string) bool {
+	for _, ip := range hostIPs(addr) {
+		if n.bans.IsBanned(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// callingPeer returns the key the caller is scored by: the node ID of peers
+// we handshook with, the IP of any other remote caller. Local calls such as
+// the HTTP gateway are not scored.
+func (n *Node) callingPeer(ctx context.Context) (id string, ip string, ok bool) {
+	ip, ok = remoteIP(ctx)
+	if !ok {
+		return "", "", false
+	}
+
+	if key, ok := peerKey(ctx); ok {
+		n.peerLock.RLock()
+		_, handshook := n.peers[NodeID(key)]
+		n.peerLock.RUnlock()
+		if handshook {
+			return NodeID(key), ip, true
+		}
+	}
+
+	return ip, ip, true
+}
+
+func (n *Node) rewardPeer(ctx context.Context, points int) {
+	if id, _, ok := n.callingPeer(ctx); ok {
+		n.scores.add(id, points)
+	}
+}
+
+// penalizePeer lowers the score of the calling peer. Once the score reaches
+// banScore its IP is banned, and its node ID if it handshook, node IDs
+// costing nothing to replace.
+func (n *Node) penalizePeer(ctx context.Context, points int, reason string) {
+	id, ip, ok := n.callingPeer(ctx)
+	if !ok || points == 0 {
+		return
+	}
+
+	score := n.scores.add(id, -points)
+	n.p2pLogger.Debugw("Penalized peer", "id", id, "reason", reason, "score", score)
+	if score > banScore {
+		return
+	}
+
+	if id != ip {
+		if err := n.banNode(id, n.banDuration(), reason); err != nil {
+			n.p2pLogger.Errorw("Ban error", "id", id, "error", err)
+		}
+	}
+	if err := n.banIP(ip, n.banDuration(), reason); err != nil {
+		n.p2pLogger.Errorw("Ban error", "ip", ip, "error", err)
+	}
+}
+
+// checkRate counts a message of the calling peer and penalizes it for spam
+// above maxMessagesPerSecond.
+func (n *Node) checkRate(ctx context.Context) error {
+	id, _, ok := n.callingPeer(ctx)
+	if !ok || n.scores.message(id) {
+		return nil
+	}
+
+	n.penalizePeer(ctx, penaltySpam, "spam")
+
+	return status.Error(codes.ResourceExhausted, "too many messages")
+}
+
+// banNode bans the node ID and disconnects the peer with it.
+func (n *Node) banNode(id string, d time.Duration, reason string) error {
+	if err := n.bans.BanNode(id, d, reason); err != nil {
+		return err
+	}
+	n.scores.reset(id)
+
+	for _, p := range n.getPeers() {
+		if p.id == id {
+			n.deletePeer(p)
+		}
+	}
+
+	n.p2pLogger.Infow("Banned peer", "id", id, "reason", reason, "duration", d)
+
+	return nil
+}
+
+// banIP bans the IP and disconnects the peers at it.
+func (n *Node) banIP(ip string, d time.Duration, reason string) error {
+	if err := n.bans.Ban(ip, d, reason); err != nil {
+		return err
+	}
+	n.scores.reset(ip)
+
+	for _, p := range n.getPeers() {
+		for _, peerIP := range hostIPs(p.addr) {
+			if peerIP == ip {
+				n.deletePeer(p)
+				break
+			}
+		}
+	}
+
+	n.p2pLogger.Infow("Banned peer", "ip", ip, "reason", reason, "duration", d)
+
+	return nil
+}
+
+// checkBanned refuses the calls of banned IPs and node IDs.
+func (n *Node) checkBanned(ctx context.Context) error {
+	if ip, ok := remoteIP(ctx); ok && n.bans.IsBanned(ip) {
+		return status.Error(codes.PermissionDenied, "banned")
+	}
+	if key, ok := peerKey(ctx); ok && n.bans.IsBanned(NodeID(key)) {
+		return status.Error(codes.PermissionDenied, "banned")
+	}
+
+	return nil
+}
+
+// banInterceptor refuses the unary calls of banned callers to any service but
+// Admin, so the operator can always lift a ban.
+func (n *Node) banInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !strings.HasPrefix(info.FullMethod, "/"+proto.Admin_ServiceDesc.ServiceName+"/") {
+		if err := n.checkBanned(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+// banStreamInterceptor refuses the streams of banned callers to any service.
+func (n *Node) banStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := n.checkBanned(ss.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// isBannedRequest reports whether an HTTP request comes from a banned IP.
+func (n *Node) isBannedRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	return err == nil && n.bans.IsBanned(host)
+}
@@ -0,0 +1,161 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+
+	bans, err := NewBanList(path)
+	require.Nil(t, err)
+	require.Nil(t, bans.Ban("10.0.0.1", time.Hour, "spam"))
+	require.Nil(t, bans.Ban("10.0.0.2", -time.Second, "expired"))
+	assert.NotNil(t, bans.Ban("not an ip", time.Hour, ""))
+	assert.True(t, bans.IsBanned("10.0.0.1"))
+	assert.False(t, bans.IsBanned("10.0.0.2"))
+
+	// bans survive a restart
+	bans, err = NewBanList(path)
+	require.Nil(t, err)
+	entries := bans.List()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "10.0.0.1", entries[0].IP)
+	assert.Equal(t, "spam", entries[0].Reason)
+
+	require.Nil(t, bans.Unban("10.0.0.1"))
+	bans, err = NewBanList(path)
+	require.Nil(t, err)
+	assert.False(t, bans.IsBanned("10.0.0.1"))
+}
+
+func forgedTransaction(t *testing.T, chain *Chain) *proto.Transaction {
+	tx := spendGenesis(t, chain)
+	signature := types.SignTransaction(crypto.GeneratePrivateKey(), tx)
+	tx.Inputs[0].Signature = signature.Bytes()
+
+	return tx
+}
+
+// handshake connects from with to and returns the peer of from.
+func handshake(t *testing.T, from *Node, to *Node) *remotePeer {
+	p, v, err := from.dialRemoteNode(to.ListenAddr)
+	require.Nil(t, err)
+	from.addPeer(p, v)
+
+	return p
+}
+
+func TestMisbehavingPeerIsBanned(t *testing.T) {
+	var (
+		n      = listeningNode(t, ServerConfig{})
+		sender = listeningNode(t, ServerConfig{})
+		other  = listeningNode(t, ServerConfig{})
+		p      = handshake(t, sender, n)
+		admin  = NewAdminServer(n)
+	)
+	// another peer of n shares the IP of the sender, and goes with it
+	handshake(t, other, n)
+
+	tx := spendGenesis(t, n.chain)
+	signInputs(tx)
+	_, err := p.client.HandleTransaction(context.Background(), tx)
+	require.Nil(t, err)
+	scores, err := admin.GetPeerScores(context.Background(), &proto.PeerScoresRequest{})
+	require.Nil(t, err)
+	assert.Equal(t, int32(rewardTx), scores.Scores[sender.ID()])
+
+	for i := 0; i < 3; i++ {
+		_, err = p.client.HandleTransaction(context.Background(), forgedTransaction(t, n.chain))
+		require.NotNil(t, err)
+	}
+
+	// both the node ID and the IP of the sender are banned, a new node key
+	// does not get it back in
+	list, err := admin.ListBans(context.Background(), &proto.ListBansRequest{})
+	require.Nil(t, err)
+	require.Len(t, list.Bans, 2)
+	assert.True(t, n.bans.IsBanned(sender.ID()))
+	assert.True(t, n.bans.IsBanned("127.0.0.1"))
+	assert.Empty(t, n.getPeerList())
+
+	_, err = p.client.HandleTransaction(context.Background(), tx)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, _, err = sender.dialRemoteNode(n.ListenAddr)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, _, err = NewNode(ServerConfig{ListenAddr: sender.ListenAddr}).dialRemoteNode(n.ListenAddr)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = admin.Unban(context.Background(), &proto.UnbanRequest{NodeId: sender.ID()})
+	require.Nil(t, err)
+	list, err = admin.Unban(context.Background(), &proto.UnbanRequest{Ip: "127.0.0.1"})
+	require.Nil(t, err)
+	assert.Empty(t, list.Bans)
+	_, err = p.client.HandleTransaction(context.Background(), tx)
+	assert.Nil(t, err)
+
+	_, err = admin.Ban(context.Background(), &proto.BanRequest{Ip: "localhost"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = admin.Ban(context.Background(), &proto.BanRequest{NodeId: "localhost"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUnauthenticatedCallerIsBanned(t *testing.T) {
+	var (
+		n      = listeningNode(t, ServerConfig{})
+		tlsCfg = crypto.NewTLSConfig(nil, false, func(*crypto.PublicKey) error { return nil })
+	)
+
+	// a client that never handshook keeps sending invalid transactions
+	conn, err := grpc.Dial(n.ListenAddr, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := proto.NewNodeClient(conn)
+
+	_, err = client.HandleTransaction(context.Background(), forgedTransaction(t, n.chain))
+	require.NotNil(t, err)
+	assert.Equal(t, map[string]int32{"127.0.0.1": -penaltyBadSignature}, n.scores.all())
+	assert.False(t, n.bans.IsBanned("127.0.0.1"))
+
+	_, err = client.HandleTransaction(context.Background(), forgedTransaction(t, n.chain))
+	require.NotNil(t, err)
+	assert.True(t, n.bans.IsBanned("127.0.0.1"))
+
+	// the ban covers every service on the server, streams included
+	_, err = client.Ping(context.Background(), &proto.PingRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	query := proto.NewQueryClient(conn)
+	_, err = query.GetTip(context.Background(), &proto.TipRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	stream, err := query.Subscribe(context.Background(), &proto.SubscribeRequest{})
+	require.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestTxPenalty(t *testing.T) {
+	n := NewNode(ServerConfig{})
+
+	err := n.chain.ValidateTransaction(forgedTransaction(t, n.chain))
+	assert.Equal(t, penaltyBadSignature, txPenalty(err))
+
+	missing := spendGenesis(t, n.chain)
+	missing.Inputs[0].PrevTxHash = make([]byte, 32)
+	signInputs(missing)
+	err = n.chain.ValidateTransaction(missing)
+	assert.Equal(t, 0, txPenalty(err))
+	assert.Equal(t, 0, txPenalty(ErrTxNotFinal))
+	assert.Equal(t, penaltyConflictingTx, txPenalty(ErrDoubleSpend))
+}
@@ -5,22 +5,29 @@ import (
 	"fmt"
 	"github.com/cmkqwerty/blocker/proto"
 	"github.com/cmkqwerty/blocker/types"
+	"sort"
 	"sync"
 )
 
+// UTXOStorer holds the unspent outputs. Spent outputs are deleted, so an
+// output missing from the store is either spent or never existed.
 type UTXOStorer interface {
 	Put(*UTXO) error
 	Get(string) (*UTXO, error)
+	Delete(string) error
+	ListByAddress([]byte) ([]*UTXO, error)
 }
 
 type MemoryUTXOStore struct {
-	lock sync.RWMutex
-	data map[string]*UTXO
+	lock      sync.RWMutex
+	data      map[string]*UTXO
+	byAddress map[string]map[string]*UTXO
 }
 
 func NewMemoryUTXOStore() *MemoryUTXOStore {
 	return &MemoryUTXOStore{
-		data: make(map[string]*UTXO),
+		data:      make(map[string]*UTXO),
+		byAddress: make(map[string]map[string]*UTXO),
 	}
 }
 
@@ -31,6 +38,15 @@ func (m *MemoryUTXOStore) Put(utxo *UTXO) error {
 	key := fmt.Sprintf("%s_%d", utxo.Hash, utxo.OutIndex)
 	m.data[key] = utxo
 
+	// outputs locked by a multisig lock or script have no address to index
+	if len(utxo.Address) > 0 {
+		address := hex.EncodeToString(utxo.Address)
+		if m.byAddress[address] == nil {
+			m.byAddress[address] = make(map[string]*UTXO)
+		}
+		m.byAddress[address][key] = utxo
+	}
+
 	return nil
 }
 
@@ -46,6 +62,46 @@ func (m *MemoryUTXOStore) Get(hash string) (*UTXO, error) {
 	return utxo, nil
 }
 
+func (m *MemoryUTXOStore) Delete(hash string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	utxo, ok := m.data[hash]
+	if !ok {
+		return fmt.Errorf("utxo with hash [%s] does not exist", hash)
+	}
+	delete(m.data, hash)
+
+	address := hex.EncodeToString(utxo.Address)
+	delete(m.byAddress[address], hash)
+	if len(m.byAddress[address]) == 0 {
+		delete(m.byAddress, address)
+	}
+
+	return nil
+}
+
+// ListByAddress returns the unspent outputs of the address ordered by
+// transaction hash and output index.
+func (m *MemoryUTXOStore) ListByAddress(address []byte) ([]*UTXO, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	indexed := m.byAddress[hex.EncodeToString(address)]
+	utxos := make([]*UTXO, 0, len(indexed))
+	for _, utxo := range indexed {
+		utxos = append(utxos, utxo)
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Hash != utxos[j].Hash {
+			return utxos[i].Hash < utxos[j].Hash
+		}
+		return utxos[i].OutIndex < utxos[j].OutIndex
+	})
+
+	return utxos, nil
+}
+
 type TXStorer interface {
 	Put(*proto.Transaction) error
 	Get(string) (*proto.Transaction, error)
@@ -121,3 +177,41 @@ func (m *MemoryBlockStore) Get(hash string) (*proto.Block, error) {
 
 	return block, nil
 }
+
+type AnchorStorer interface {
+	Put(*proto.Anchor) error
+	Get(string) (*proto.Anchor, error)
+}
+
+type MemoryAnchorStore struct {
+	lock    sync.RWMutex
+	anchors map[string]*proto.Anchor
+}
+
+func NewMemoryAnchorStore() *MemoryAnchorStore {
+	return &MemoryAnchorStore{
+		anchors: make(map[string]*proto.Anchor),
+	}
+}
+
+func (m *MemoryAnchorStore) Put(anchor *proto.Anchor) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	hash := hex.EncodeToString(anchor.PayloadHash)
+	m.anchors[hash] = anchor
+
+	return nil
+}
+
+func (m *MemoryAnchorStore) Get(hash string) (*proto.Anchor, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	anchor, ok := m.anchors[hash]
+	if !ok {
+		return nil, fmt.Errorf("anchor with hash [%s] does not exist", hash)
+	}
+
+	return anchor, nil
+}
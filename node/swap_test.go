@@ -0,0 +1,133 @@
+package node
+
+import (
+	"crypto/sha256"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func addBlockWithTxs(t *testing.T, chain *Chain, txx ...*proto.Transaction) *proto.Block {
+	block := randomBlock(t, chain)
+	block.Transactions = append(block.Transactions, txx...)
+	types.SignBlock(crypto.GeneratePrivateKey(), block)
+	require.Nil(t, chain.AddBlock(block))
+
+	return block
+}
+
+// fund sends the genesis coins of the chain to the owner.
+func fund(t *testing.T, chain *Chain, owner *crypto.PrivateKey) *proto.Transaction {
+	tx := spendGenesis(t, chain)
+	tx.Outputs[0].Address = owner.Public().Address().Bytes()
+	signInputs(tx)
+	addBlockWithTxs(t, chain, tx)
+
+	return tx
+}
+
+func spend(prevTx *proto.Transaction, output *proto.TxOutput) *proto.Transaction {
+	return &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   types.HashTransaction(prevTx),
+				PrevOutIndex: 0,
+			},
+		},
+		Outputs: []*proto.TxOutput{output},
+	}
+}
+
+func lockHTLC(t *testing.T, chain *Chain, owner *crypto.PrivateKey, prevTx *proto.Transaction, output *proto.TxOutput) *proto.Transaction {
+	tx := spend(prevTx, output)
+	tx.Inputs[0].PublicKey = owner.Public().Bytes()
+	tx.Inputs[0].Signature = types.SignTransaction(owner, tx).Bytes()
+	addBlockWithTxs(t, chain, tx)
+
+	return tx
+}
+
+func TestAtomicSwap(t *testing.T) {
+	var (
+		chainA   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		chainB   = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		alice    = crypto.GeneratePrivateKey()
+		bob      = crypto.GeneratePrivateKey()
+		preimage = []byte("alice's swap secret")
+		hash     = sha256.Sum256(preimage)
+	)
+
+	// alice owns coins on chain A, bob on chain B
+	aliceFunds := fund(t, chainA, alice)
+	bobFunds := fund(t, chainB, bob)
+
+	// alice locks her coins for bob with a long timeout, then bob locks his
+	// coins for alice under the same hash with a shorter one
+	timeoutA := int64(chainA.Height() + 20)
+	htlcA := lockHTLC(t, chainA, alice, aliceFunds,
+		types.NewHTLCOutput(1000, hash[:], bob.Public(), alice.Public(), timeoutA))
+
+	timeoutB := int64(chainB.Height() + 10)
+	htlcB := lockHTLC(t, chainB, bob, bobFunds,
+		types.NewHTLCOutput(1000, hash[:], alice.Public(), bob.Public(), timeoutB))
+
+	// bob cannot claim alice's coins without the preimage
+	guess := spend(htlcA, &proto.TxOutput{Amount: 1000, Address: bob.Public().Address().Bytes()})
+	require.Nil(t, types.ClaimHTLC(bob, guess, 0, []byte("guess")))
+	require.NotNil(t, chainA.ValidateTransaction(guess))
+
+	// alice claims bob's coins on chain B, revealing the preimage
+	claimB := spend(htlcB, &proto.TxOutput{Amount: 1000, Address: alice.Public().Address().Bytes()})
+	require.Nil(t, types.ClaimHTLC(alice, claimB, 0, preimage))
+	block := addBlockWithTxs(t, chainB, claimB)
+
+	// bob learns the preimage from chain B and claims alice's coins on chain A
+	revealed, err := types.ExtractHTLCPreimage(block.Transactions[len(block.Transactions)-1].Inputs[0])
+	require.Nil(t, err)
+
+	claimA := spend(htlcA, &proto.TxOutput{Amount: 1000, Address: bob.Public().Address().Bytes()})
+	require.Nil(t, types.ClaimHTLC(bob, claimA, 0, revealed))
+	addBlockWithTxs(t, chainA, claimA)
+
+	_, err = chainA.utxoStore.Get(utxoKey(types.HashTransaction(claimA), 0))
+	require.Nil(t, err)
+	_, err = chainB.utxoStore.Get(utxoKey(types.HashTransaction(claimB), 0))
+	require.Nil(t, err)
+}
+
+func TestHTLCRefund(t *testing.T) {
+	var (
+		chain    = NewChain(NewMemoryBlockStore(), NewMemoryTXStore())
+		alice    = crypto.GeneratePrivateKey()
+		bob      = crypto.GeneratePrivateKey()
+		preimage = []byte("never revealed")
+		hash     = sha256.Sum256(preimage)
+	)
+
+	funds := fund(t, chain, alice)
+	timeout := int64(chain.Height() + 3)
+	htlc := lockHTLC(t, chain, alice, funds,
+		types.NewHTLCOutput(1000, hash[:], bob.Public(), alice.Public(), timeout))
+
+	refund := spend(htlc, &proto.TxOutput{Amount: 1000, Address: alice.Public().Address().Bytes()})
+	require.Nil(t, types.RefundHTLC(alice, refund, 0, timeout))
+
+	for int64(chain.Height()) < timeout {
+		require.ErrorIs(t, chain.ValidateTransaction(refund), ErrTxNotFinal)
+		addBlockWithTxs(t, chain)
+	}
+	require.Nil(t, chain.ValidateTransaction(refund))
+
+	// bob cannot take the refund path
+	stolen := spend(htlc, &proto.TxOutput{Amount: 1000, Address: bob.Public().Address().Bytes()})
+	require.Nil(t, types.RefundHTLC(bob, stolen, 0, timeout))
+	require.NotNil(t, chain.ValidateTransaction(stolen))
+
+	// lowering the lock time below the timeout does not unlock the refund
+	early := spend(htlc, &proto.TxOutput{Amount: 1000, Address: alice.Public().Address().Bytes()})
+	require.Nil(t, types.RefundHTLC(alice, early, 0, timeout-1))
+	require.NotNil(t, chain.ValidateTransaction(early))
+}
@@ -0,0 +1,111 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+)
+
+const (
+	tracerName  = "github.com/cmkqwerty/blocker/node"
+	serviceName = "blocker"
+)
+
+// Trace exporters known by NewTracerProvider.
+const (
+	TraceExporterStdout = "stdout"
+	TraceExporterOTLP   = "otlp"
+)
+
+// tracePropagator carries the trace context between nodes in the gRPC
+// metadata.
+var tracePropagator = propagation.TraceContext{}
+
+// NewTracerProvider returns a provider exporting the spans of the node
+// listening on listenAddr. The OTLP exporter sends them over gRPC to the
+// collector configured by the OTEL_EXPORTER_OTLP_* environment, by default
+// localhost:4317.
+func NewTracerProvider(exporter string, listenAddr string) (*sdktrace.TracerProvider, error) {
+	var (
+		spanExporter sdktrace.SpanExporter
+		err          error
+	)
+	switch exporter {
+	case TraceExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case TraceExporterOTLP:
+		spanExporter, err = otlptracegrpc.New(context.Background(), otlptracegrpc.WithInsecure())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceInstanceID(listenAddr),
+		)),
+	), nil
+}
+
+func (n *Node) tracerProvider() trace.TracerProvider {
+	if n.TracerProvider == nil {
+		return noop.NewTracerProvider()
+	}
+
+	return n.TracerProvider
+}
+
+func (n *Node) grpcServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler(
+			otelgrpc.WithTracerProvider(n.tracerProvider()),
+			otelgrpc.WithPropagators(tracePropagator),
+		)),
+		grpc.ChainUnaryInterceptor(n.metrics.unaryInterceptor, n.banInterceptor),
+		grpc.ChainStreamInterceptor(n.metrics.streamInterceptor, n.banStreamInterceptor),
+	}
+}
+
+func (n *Node) grpcDialOptions(id string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(n.clientCredentials(id)),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+			otelgrpc.WithTracerProvider(n.tracerProvider()),
+			otelgrpc.WithPropagators(tracePropagator),
+		)),
+	}
+}
+
+func (n *Node) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return n.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// detachSpan keeps the span of ctx for work that outlives the request, e.g. an
+// asynchronous broadcast, without its cancellation.
+func detachSpan(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
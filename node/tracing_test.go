@@ -0,0 +1,73 @@
+package node
+
+import (
+	"context"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+	"time"
+)
+
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+
+	return nil
+}
+
+func TestTracePropagation(t *testing.T) {
+	var (
+		senderSpans   = tracetest.NewSpanRecorder()
+		receiverSpans = tracetest.NewSpanRecorder()
+		sender        = NewNode(ServerConfig{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(senderSpans))})
+		receiver      = NewNode(ServerConfig{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(receiverSpans))})
+	)
+
+	addr := serveNode(t, receiver)
+	p, err := sender.dialPeer(addr, "")
+	require.Nil(t, err)
+	sender.addPeer(p, &proto.Version{ListenAddr: addr, PublicKey: randomNodeKey()})
+
+	tx := spendGenesis(t, sender.chain)
+	signInputs(tx)
+	_, err = sender.HandleTransaction(context.Background(), tx)
+	require.Nil(t, err)
+
+	// the client span of the sender ends once the receiver answered
+	require.Eventually(t, func() bool {
+		return receiver.mempool.Has(tx) &&
+			findSpan(receiverSpans.Ended(), "HandleTransaction") != nil &&
+			hasRemoteParent(receiverSpans.Ended(), senderSpans.Ended())
+	}, 5*time.Second, 10*time.Millisecond)
+
+	handled := findSpan(senderSpans.Ended(), "HandleTransaction")
+	require.NotNil(t, handled)
+	assert.NotNil(t, findSpan(senderSpans.Ended(), "ValidateTransaction"))
+
+	// the receiver continues the trace started by the sender
+	received := findSpan(receiverSpans.Ended(), "HandleTransaction")
+	assert.Equal(t, handled.SpanContext().TraceID(), received.SpanContext().TraceID())
+}
+
+// hasRemoteParent reports whether a span is the child of a span of another
+// node.
+func hasRemoteParent(spans []sdktrace.ReadOnlySpan, parents []sdktrace.ReadOnlySpan) bool {
+	for _, span := range spans {
+		if !span.Parent().IsRemote() {
+			continue
+		}
+		for _, parent := range parents {
+			if parent.SpanContext().SpanID() == span.Parent().SpanID() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// TxLocation is the position of a transaction in the chain.
+type TxLocation struct {
+	BlockHash []byte
+	Height    int
+	// Index of the transaction in the block.
+	Index int
+}
+
+// TxIndexer maps transaction hashes to their location and addresses to the
+// transactions paying to or spending from them. The chain feeds it every
+// block it adds; it can be rebuilt from the block store at any time.
+type TxIndexer interface {
+	Put(hash string, location *TxLocation, addresses [][]byte) error
+	Get(hash string) (*TxLocation, error)
+	History(address []byte) ([]string, error)
+	Reset() error
+}
+
+type MemoryTxIndex struct {
+	lock      sync.RWMutex
+	locations map[string]*TxLocation
+	history   map[string][]string
+}
+
+func NewMemoryTxIndex() *MemoryTxIndex {
+	return &MemoryTxIndex{
+		locations: make(map[string]*TxLocation),
+		history:   make(map[string][]string),
+	}
+}
+
+func (m *MemoryTxIndex) Put(hash string, location *TxLocation, addresses [][]byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.locations[hash] = location
+
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		key := hex.EncodeToString(address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		m.history[key] = append(m.history[key], hash)
+	}
+
+	return nil
+}
+
+func (m *MemoryTxIndex) Get(hash string) (*TxLocation, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	location, ok := m.locations[hash]
+	if !ok {
+		return nil, fmt.Errorf("transaction with hash [%s] is not indexed", hash)
+	}
+
+	return location, nil
+}
+
+// History returns the hashes of the transactions involving the address,
+// oldest first.
+func (m *MemoryTxIndex) History(address []byte) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	history := m.history[hex.EncodeToString(address)]
+
+	return append([]string{}, history...), nil
+}
+
+func (m *MemoryTxIndex) Reset() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.locations = make(map[string]*TxLocation)
+	m.history = make(map[string][]string)
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package script
+
+import (
+	"github.com/cmkqwerty/blocker/crypto"
+)
+
+type Builder struct {
+	script []byte
+}
+
+func NewBuilder() *Builder {
+	return &Builder{script: []byte{}}
+}
+
+func (b *Builder) AddOp(op byte) *Builder {
+	b.script = append(b.script, op)
+
+	return b
+}
+
+// AddData pushes the data with the shortest push encoding.
+func (b *Builder) AddData(data []byte) *Builder {
+	switch {
+	case len(data) == 0:
+		b.script = append(b.script, OP_FALSE)
+	case len(data) < OP_PUSHDATA1:
+		b.script = append(b.script, byte(len(data)))
+	case len(data) <= 0xff:
+		b.script = append(b.script, OP_PUSHDATA1, byte(len(data)))
+	default:
+		b.script = append(b.script, OP_PUSHDATA2, byte(len(data)), byte(len(data)>>8))
+	}
+	b.script = append(b.script, data...)
+
+	return b
+}
+
+func (b *Builder) AddInt(n int64) *Builder {
+	switch {
+	case n == 0:
+		return b.AddOp(OP_FALSE)
+	case n >= 1 && n <= 16:
+		return b.AddOp(byte(OP_TRUE + n - 1))
+	}
+
+	return b.AddData(number(n))
+}
+
+func (b *Builder) Script() []byte {
+	return b.script
+}
+
+// PayToAddress locks an output to the owner of the address. It is unlocked
+// with <signature> <public key>.
+func PayToAddress(address crypto.Address) []byte {
+	return NewBuilder().
+		AddOp(OP_DUP).
+		AddOp(OP_ADDRESS).
+		AddData(address.Bytes()).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+func PayToAddressUnlock(signature *crypto.Signature, publicKey *crypto.PublicKey) []byte {
+	return NewBuilder().
+		AddData(signature.Bytes()).
+		AddData(publicKey.Bytes()).
+		Script()
+}
+
+// Multisig locks an output to threshold signatures of the keys. It is
+// unlocked with the signatures in the order of their keys.
+func Multisig(threshold int, publicKeys ...*crypto.PublicKey) []byte {
+	b := NewBuilder().AddInt(int64(threshold))
+	for _, publicKey := range publicKeys {
+		b.AddData(publicKey.Bytes())
+	}
+
+	return b.AddInt(int64(len(publicKeys))).AddOp(OP_CHECKMULTISIG).Script()
+}
+
+func MultisigUnlock(signatures ...*crypto.Signature) []byte {
+	b := NewBuilder()
+	for _, signature := range signatures {
+		b.AddData(signature.Bytes())
+	}
+
+	return b.Script()
+}
+
+// HashLock locks an output to whoever reveals the SHA-256 preimage of hash.
+func HashLock(hash []byte) []byte {
+	return NewBuilder().
+		AddOp(OP_SHA256).
+		AddData(hash).
+		AddOp(OP_EQUAL).
+		Script()
+}
+
+// TimeLock locks an output to the address until the chain reaches the lock
+// time, a height or a unix timestamp.
+func TimeLock(lockTime int64, address crypto.Address) []byte {
+	prefix := NewBuilder().
+		AddInt(lockTime).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).
+		Script()
+
+	return append(prefix, PayToAddress(address)...)
+}
+
+// HTLC locks an output to the recipient revealing the SHA-256 preimage of
+// hash, or to the refund key once the chain passed the timeout.
+func HTLC(hash []byte, recipient *crypto.PublicKey, refund *crypto.PublicKey, timeout int64) []byte {
+	return NewBuilder().
+		AddOp(OP_IF).
+		AddOp(OP_SHA256).
+		AddData(hash).
+		AddOp(OP_EQUALVERIFY).
+		AddData(recipient.Bytes()).
+		AddOp(OP_ELSE).
+		AddInt(timeout).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).
+		AddData(refund.Bytes()).
+		AddOp(OP_ENDIF).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+func HTLCClaimUnlock(signature *crypto.Signature, preimage []byte) []byte {
+	return NewBuilder().
+		AddData(signature.Bytes()).
+		AddData(preimage).
+		AddOp(OP_TRUE).
+		Script()
+}
+
+func HTLCRefundUnlock(signature *crypto.Signature) []byte {
+	return NewBuilder().
+		AddData(signature.Bytes()).
+		AddOp(OP_FALSE).
+		Script()
+}
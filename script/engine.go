@@ -0,0 +1,436 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+)
+
+const (
+	MaxScriptSize   = 1024
+	MaxStackSize    = 100
+	MaxElementSize  = 520
+	MaxMultisigKeys = 16
+
+	// MaxCost bounds the work a single input may cost a validator. Every
+	// instruction costs opCost and every signature check sigCost on top.
+	MaxCost = 1000
+	opCost  = 1
+	sigCost = 50
+
+	// LockTimeThreshold separates lock times given as block heights (below)
+	// from unix timestamps in seconds (at or above).
+	LockTimeThreshold = 500_000_000
+)
+
+// Context is the part of the chain state a script can observe.
+type Context struct {
+	// SigHash is the message signatures of the spending transaction commit to.
+	SigHash []byte
+	// LockTime of the spending transaction. The chain only accepts the
+	// transaction once its lock time passed, so OP_CHECKLOCKTIMEVERIFY just
+	// compares against it.
+	LockTime int64
+}
+
+type engine struct {
+	ctx   *Context
+	stack [][]byte
+	conds []bool
+	cost  int
+}
+
+// Verify runs the unlocking script followed by the locking script and
+// succeeds when the result on top of the stack is true. The unlocking script
+// may only push data.
+func Verify(unlocking []byte, locking []byte, ctx *Context) error {
+	unlock, err := Parse(unlocking)
+	if err != nil {
+		return err
+	}
+	for _, instruction := range unlock {
+		if !instruction.isPush() {
+			return fmt.Errorf("unlocking script contains %s", instruction)
+		}
+	}
+
+	lock, err := Parse(locking)
+	if err != nil {
+		return err
+	}
+
+	e := &engine{ctx: ctx}
+	if err := e.run(unlock); err != nil {
+		return err
+	}
+	if err := e.run(lock); err != nil {
+		return err
+	}
+
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return fmt.Errorf("script evaluated to false")
+	}
+
+	return nil
+}
+
+func (e *engine) run(instructions []Instruction) error {
+	for _, instruction := range instructions {
+		if err := e.step(instruction); err != nil {
+			return fmt.Errorf("%s: %w", instruction, err)
+		}
+
+		if len(e.stack) > MaxStackSize {
+			return fmt.Errorf("stack size exceeds limit %d", MaxStackSize)
+		}
+	}
+
+	if len(e.conds) > 0 {
+		return fmt.Errorf("unbalanced conditional")
+	}
+
+	return nil
+}
+
+func (e *engine) executing() bool {
+	for _, cond := range e.conds {
+		if !cond {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *engine) charge(cost int) error {
+	e.cost += cost
+	if e.cost > MaxCost {
+		return fmt.Errorf("execution cost exceeds limit %d", MaxCost)
+	}
+
+	return nil
+}
+
+func (e *engine) step(in Instruction) error {
+	if err := e.charge(opCost); err != nil {
+		return err
+	}
+
+	if !e.executing() {
+		switch in.Op {
+		case OP_IF, OP_NOTIF:
+			e.conds = append(e.conds, false)
+		case OP_ELSE, OP_ENDIF:
+			return e.endBranch(in.Op)
+		}
+
+		return nil
+	}
+
+	switch {
+	case in.Op <= OP_PUSHDATA2:
+		if len(in.Data) > MaxElementSize {
+			return fmt.Errorf("element size exceeds limit %d", MaxElementSize)
+		}
+		e.push(in.Data)
+		return nil
+	case in.Op >= OP_TRUE && in.Op <= OP_16:
+		e.push(number(int64(in.Op - OP_TRUE + 1)))
+		return nil
+	}
+
+	switch in.Op {
+	case OP_IF, OP_NOTIF:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.conds = append(e.conds, asBool(v) == (in.Op == OP_IF))
+	case OP_ELSE, OP_ENDIF:
+		return e.endBranch(in.Op)
+	case OP_VERIFY:
+		return e.verify()
+	case OP_RETURN:
+		return fmt.Errorf("output is unspendable")
+	case OP_DROP:
+		_, err := e.pop()
+		return err
+	case OP_DUP:
+		v, err := e.peek()
+		if err != nil {
+			return err
+		}
+		e.push(v)
+	case OP_SWAP:
+		a, b, err := e.pop2()
+		if err != nil {
+			return err
+		}
+		e.push(b)
+		e.push(a)
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, b, err := e.pop2()
+		if err != nil {
+			return err
+		}
+		e.push(boolean(bytes.Equal(a, b)))
+		if in.Op == OP_EQUALVERIFY {
+			return e.verify()
+		}
+	case OP_NOT:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(boolean(!asBool(v)))
+	case OP_BOOLAND, OP_BOOLOR:
+		a, b, err := e.pop2()
+		if err != nil {
+			return err
+		}
+		if in.Op == OP_BOOLAND {
+			e.push(boolean(asBool(a) && asBool(b)))
+		} else {
+			e.push(boolean(asBool(a) || asBool(b)))
+		}
+	case OP_SHA256:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(v)
+		e.push(hash[:])
+	case OP_ADDRESS:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if len(v) != crypto.PublicKeyLen {
+			return fmt.Errorf("invalid public key length (%d)", len(v))
+		}
+		e.push(crypto.PublicKeyFromBytes(v).Address().Bytes())
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		signature, publicKey, err := e.pop2()
+		if err != nil {
+			return err
+		}
+		if err := e.charge(sigCost); err != nil {
+			return err
+		}
+		e.push(boolean(e.checkSig(signature, publicKey)))
+		if in.Op == OP_CHECKSIGVERIFY {
+			return e.verify()
+		}
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		if err := e.checkMultisig(); err != nil {
+			return err
+		}
+		if in.Op == OP_CHECKMULTISIGVERIFY {
+			return e.verify()
+		}
+	case OP_CHECKLOCKTIMEVERIFY:
+		return e.checkLockTime()
+	default:
+		return fmt.Errorf("unknown opcode")
+	}
+
+	return nil
+}
+
+func (e *engine) endBranch(op byte) error {
+	if len(e.conds) == 0 {
+		return fmt.Errorf("%s without OP_IF", opcodeName(op))
+	}
+
+	if op == OP_ELSE {
+		e.conds[len(e.conds)-1] = !e.conds[len(e.conds)-1]
+	} else {
+		e.conds = e.conds[:len(e.conds)-1]
+	}
+
+	return nil
+}
+
+func (e *engine) verify() error {
+	v, err := e.pop()
+	if err != nil {
+		return err
+	}
+
+	if !asBool(v) {
+		return fmt.Errorf("verify failed")
+	}
+
+	return nil
+}
+
+func (e *engine) checkSig(signature []byte, publicKey []byte) bool {
+	if len(signature) != crypto.SignatureLen || len(publicKey) != crypto.PublicKeyLen {
+		return false
+	}
+
+	return crypto.SignatureFromBytes(signature).Verify(crypto.PublicKeyFromBytes(publicKey), e.ctx.SigHash)
+}
+
+// checkMultisig expects <sig>... <m> <key>... <n> on the stack. Signatures
+// must be given in the same order as their keys.
+func (e *engine) checkMultisig() error {
+	n, err := e.popNumber()
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > MaxMultisigKeys {
+		return fmt.Errorf("invalid number of keys (%d)", n)
+	}
+	if err := e.charge(int(n) * sigCost); err != nil {
+		return err
+	}
+
+	keys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if keys[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+
+	m, err := e.popNumber()
+	if err != nil {
+		return err
+	}
+	if m < 1 || m > n {
+		return fmt.Errorf("invalid number of signatures (%d of %d)", m, n)
+	}
+
+	signatures := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if signatures[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+
+	k := 0
+	for _, signature := range signatures {
+		for k < len(keys) && !e.checkSig(signature, keys[k]) {
+			k++
+		}
+		if k == len(keys) {
+			e.push(boolean(false))
+			return nil
+		}
+		k++
+	}
+	e.push(boolean(true))
+
+	return nil
+}
+
+func (e *engine) checkLockTime() error {
+	v, err := e.peek()
+	if err != nil {
+		return err
+	}
+
+	lockTime, err := asNumber(v)
+	if err != nil {
+		return err
+	}
+
+	if (lockTime < LockTimeThreshold) != (e.ctx.LockTime < LockTimeThreshold) {
+		return fmt.Errorf("lock time type mismatch")
+	}
+	if e.ctx.LockTime < lockTime {
+		return fmt.Errorf("locked until %d", lockTime)
+	}
+
+	return nil
+}
+
+func (e *engine) push(v []byte) {
+	e.stack = append(e.stack, v)
+}
+
+func (e *engine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, fmt.Errorf("stack is empty")
+	}
+
+	return e.stack[len(e.stack)-1], nil
+}
+
+func (e *engine) pop() ([]byte, error) {
+	v, err := e.peek()
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return v, nil
+}
+
+// pop2 pops the two topmost elements, a being the deeper one.
+func (e *engine) pop2() ([]byte, []byte, error) {
+	b, err := e.pop()
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := e.pop()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, b, nil
+}
+
+func (e *engine) popNumber() (int64, error) {
+	v, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+
+	return asNumber(v)
+}
+
+func asBool(v []byte) bool {
+	for _, b := range v {
+		if b != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func boolean(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+
+	return []byte{}
+}
+
+// Numbers are unsigned little endian integers of at most 8 bytes.
+func asNumber(v []byte) (int64, error) {
+	if len(v) > 8 {
+		return 0, fmt.Errorf("number too long (%d bytes)", len(v))
+	}
+
+	var n uint64
+	for i := len(v) - 1; i >= 0; i-- {
+		n = n<<8 | uint64(v[i])
+	}
+	if n > 1<<62 {
+		return 0, fmt.Errorf("number out of range")
+	}
+
+	return int64(n), nil
+}
+
+func number(n int64) []byte {
+	var v []byte
+	for u := uint64(n); u > 0; u >>= 8 {
+		v = append(v, byte(u))
+	}
+
+	return v
+}
@@ -0,0 +1,150 @@
+package script
+
+import (
+	"crypto/sha256"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newContext() *Context {
+	return &Context{
+		SigHash: util.RandomHash(),
+	}
+}
+
+func TestPayToAddress(t *testing.T) {
+	var (
+		ctx        = newContext()
+		privateKey = crypto.GeneratePrivateKey()
+		locking    = PayToAddress(privateKey.Public().Address())
+	)
+
+	unlocking := PayToAddressUnlock(privateKey.Sign(ctx.SigHash), privateKey.Public())
+	assert.Nil(t, Verify(unlocking, locking, ctx))
+
+	other := crypto.GeneratePrivateKey()
+	unlocking = PayToAddressUnlock(other.Sign(ctx.SigHash), other.Public())
+	assert.NotNil(t, Verify(unlocking, locking, ctx))
+
+	unlocking = PayToAddressUnlock(privateKey.Sign(util.RandomHash()), privateKey.Public())
+	assert.NotNil(t, Verify(unlocking, locking, ctx))
+}
+
+func TestMultisig(t *testing.T) {
+	var (
+		ctx     = newContext()
+		alice   = crypto.GeneratePrivateKey()
+		bob     = crypto.GeneratePrivateKey()
+		carol   = crypto.GeneratePrivateKey()
+		locking = Multisig(2, alice.Public(), bob.Public(), carol.Public())
+	)
+
+	assert.Nil(t, Verify(MultisigUnlock(alice.Sign(ctx.SigHash), carol.Sign(ctx.SigHash)), locking, ctx))
+	assert.NotNil(t, Verify(MultisigUnlock(carol.Sign(ctx.SigHash), alice.Sign(ctx.SigHash)), locking, ctx))
+	assert.NotNil(t, Verify(MultisigUnlock(alice.Sign(ctx.SigHash), alice.Sign(ctx.SigHash)), locking, ctx))
+	assert.NotNil(t, Verify(MultisigUnlock(alice.Sign(ctx.SigHash)), locking, ctx))
+}
+
+func TestHashLock(t *testing.T) {
+	var (
+		ctx      = newContext()
+		preimage = []byte("secret")
+		hash     = sha256.Sum256(preimage)
+		locking  = HashLock(hash[:])
+	)
+
+	assert.Nil(t, Verify(NewBuilder().AddData(preimage).Script(), locking, ctx))
+	assert.NotNil(t, Verify(NewBuilder().AddData([]byte("guess")).Script(), locking, ctx))
+}
+
+func TestTimeLock(t *testing.T) {
+	var (
+		ctx        = newContext()
+		privateKey = crypto.GeneratePrivateKey()
+		unlocking  = PayToAddressUnlock(privateKey.Sign(ctx.SigHash), privateKey.Public())
+	)
+
+	assert.NotNil(t, Verify(unlocking, TimeLock(10, privateKey.Public().Address()), ctx))
+
+	ctx.LockTime = 10
+	assert.Nil(t, Verify(unlocking, TimeLock(10, privateKey.Public().Address()), ctx))
+	assert.NotNil(t, Verify(unlocking, TimeLock(11, privateKey.Public().Address()), ctx))
+	assert.Nil(t, Verify(unlocking, TimeLock(5, privateKey.Public().Address()), ctx))
+
+	ctx.LockTime = 1_700_000_000
+	assert.Nil(t, Verify(unlocking, TimeLock(ctx.LockTime, privateKey.Public().Address()), ctx))
+	assert.NotNil(t, Verify(unlocking, TimeLock(ctx.LockTime+1, privateKey.Public().Address()), ctx))
+	assert.NotNil(t, Verify(unlocking, TimeLock(10, privateKey.Public().Address()), ctx))
+}
+
+func TestConditionals(t *testing.T) {
+	ctx := newContext()
+	locking := NewBuilder().
+		AddOp(OP_IF).
+		AddInt(2).
+		AddOp(OP_ELSE).
+		AddOp(OP_FALSE).
+		AddOp(OP_IF).
+		AddOp(OP_RETURN).
+		AddOp(OP_ELSE).
+		AddInt(3).
+		AddOp(OP_ENDIF).
+		AddOp(OP_ENDIF).
+		AddInt(3).
+		AddOp(OP_EQUAL).
+		Script()
+
+	assert.NotNil(t, Verify(NewBuilder().AddInt(1).Script(), locking, ctx))
+	assert.Nil(t, Verify(NewBuilder().AddInt(0).Script(), locking, ctx))
+
+	unbalanced := NewBuilder().AddOp(OP_TRUE).AddOp(OP_IF).AddOp(OP_TRUE).Script()
+	assert.NotNil(t, Verify(nil, unbalanced, ctx))
+}
+
+func TestBooleanLogic(t *testing.T) {
+	ctx := newContext()
+	locking := NewBuilder().AddOp(OP_BOOLOR).AddOp(OP_NOT).AddOp(OP_NOT).Script()
+
+	assert.Nil(t, Verify(NewBuilder().AddInt(0).AddInt(1).Script(), locking, ctx))
+	assert.NotNil(t, Verify(NewBuilder().AddInt(0).AddInt(0).Script(), locking, ctx))
+
+	locking = NewBuilder().AddOp(OP_BOOLAND).Script()
+	assert.Nil(t, Verify(NewBuilder().AddInt(1).AddInt(1).Script(), locking, ctx))
+	assert.NotNil(t, Verify(NewBuilder().AddInt(1).AddInt(0).Script(), locking, ctx))
+}
+
+func TestLimits(t *testing.T) {
+	ctx := newContext()
+
+	// unlocking scripts may only push data
+	assert.NotNil(t, Verify(NewBuilder().AddOp(OP_TRUE).AddOp(OP_DUP).Script(), []byte{OP_TRUE}, ctx))
+
+	// signature checks are charged against the cost limit
+	privateKey := crypto.GeneratePrivateKey()
+	b := NewBuilder()
+	for i := 0; i < MaxCost/sigCost; i++ {
+		b.AddData(privateKey.Public().Bytes()).AddOp(OP_DUP).AddOp(OP_CHECKSIG).AddOp(OP_DROP)
+	}
+	assert.NotNil(t, Verify(nil, b.AddOp(OP_TRUE).Script(), ctx))
+
+	b = NewBuilder()
+	for i := 0; i <= MaxStackSize; i++ {
+		b.AddOp(OP_TRUE)
+	}
+	assert.NotNil(t, Verify(nil, b.Script(), ctx))
+
+	_, err := Parse(make([]byte, MaxScriptSize+1))
+	assert.NotNil(t, err)
+
+	_, err = Parse([]byte{OP_PUSHDATA1, 10, 1})
+	assert.NotNil(t, err)
+}
+
+func TestDisassemble(t *testing.T) {
+	s, err := Disassemble(NewBuilder().AddInt(2).AddData([]byte{0xab}).AddOp(OP_CHECKSIG).Script())
+	require.Nil(t, err)
+	assert.Equal(t, "OP_2 ab OP_CHECKSIG", s)
+}
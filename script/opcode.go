@@ -0,0 +1,154 @@
+package script
+
+import "fmt"
+
+const (
+	OP_FALSE     = 0x00
+	OP_PUSHDATA1 = 0x4c
+	OP_PUSHDATA2 = 0x4d
+	OP_TRUE      = 0x51
+	OP_2         = 0x52
+	OP_16        = 0x60
+
+	OP_IF     = 0x63
+	OP_NOTIF  = 0x64
+	OP_ELSE   = 0x67
+	OP_ENDIF  = 0x68
+	OP_VERIFY = 0x69
+	OP_RETURN = 0x6a
+
+	OP_DROP = 0x75
+	OP_DUP  = 0x76
+	OP_SWAP = 0x7c
+
+	OP_EQUAL       = 0x87
+	OP_EQUALVERIFY = 0x88
+
+	OP_NOT     = 0x91
+	OP_BOOLAND = 0x9a
+	OP_BOOLOR  = 0x9b
+
+	OP_SHA256  = 0xa8
+	OP_ADDRESS = 0xa9 // replaces a public key with its address
+
+	OP_CHECKSIG            = 0xac
+	OP_CHECKSIGVERIFY      = 0xad
+	OP_CHECKMULTISIG       = 0xae
+	OP_CHECKMULTISIGVERIFY = 0xaf
+
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+)
+
+var opcodeNames = map[byte]string{
+	OP_FALSE:               "OP_FALSE",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_PUSHDATA2:           "OP_PUSHDATA2",
+	OP_TRUE:                "OP_TRUE",
+	OP_IF:                  "OP_IF",
+	OP_NOTIF:               "OP_NOTIF",
+	OP_ELSE:                "OP_ELSE",
+	OP_ENDIF:               "OP_ENDIF",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_SWAP:                "OP_SWAP",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_NOT:                 "OP_NOT",
+	OP_BOOLAND:             "OP_BOOLAND",
+	OP_BOOLOR:              "OP_BOOLOR",
+	OP_SHA256:              "OP_SHA256",
+	OP_ADDRESS:             "OP_ADDRESS",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+func opcodeName(op byte) string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	if op >= OP_2 && op <= OP_16 {
+		return fmt.Sprintf("OP_%d", op-OP_TRUE+1)
+	}
+
+	return fmt.Sprintf("OP_UNKNOWN(0x%02x)", op)
+}
+
+// Instruction is a single parsed opcode with the data it pushes, if any.
+type Instruction struct {
+	Op   byte
+	Data []byte
+}
+
+func (i Instruction) isPush() bool {
+	return i.Op <= OP_PUSHDATA2 || (i.Op >= OP_TRUE && i.Op <= OP_16)
+}
+
+func (i Instruction) String() string {
+	if i.Op > OP_FALSE && i.Op <= OP_PUSHDATA2 {
+		return fmt.Sprintf("%x", i.Data)
+	}
+
+	return opcodeName(i.Op)
+}
+
+// Parse splits a script into instructions.
+func Parse(script []byte) ([]Instruction, error) {
+	if len(script) > MaxScriptSize {
+		return nil, fmt.Errorf("script size %d exceeds limit %d", len(script), MaxScriptSize)
+	}
+
+	var instructions []Instruction
+	for pc := 0; pc < len(script); {
+		op := script[pc]
+		pc++
+
+		var size int
+		switch {
+		case op > OP_FALSE && op < OP_PUSHDATA1:
+			size = int(op)
+		case op == OP_PUSHDATA1:
+			if pc+1 > len(script) {
+				return nil, fmt.Errorf("truncated %s", opcodeName(op))
+			}
+			size = int(script[pc])
+			pc++
+		case op == OP_PUSHDATA2:
+			if pc+2 > len(script) {
+				return nil, fmt.Errorf("truncated %s", opcodeName(op))
+			}
+			size = int(script[pc]) | int(script[pc+1])<<8
+			pc += 2
+		}
+
+		if pc+size > len(script) {
+			return nil, fmt.Errorf("push of %d bytes exceeds script", size)
+		}
+		instructions = append(instructions, Instruction{Op: op, Data: script[pc : pc+size]})
+		pc += size
+	}
+
+	return instructions, nil
+}
+
+// Disassemble returns a human readable form of the script.
+func Disassemble(script []byte) (string, error) {
+	instructions, err := Parse(script)
+	if err != nil {
+		return "", err
+	}
+
+	s := ""
+	for i, instruction := range instructions {
+		if i > 0 {
+			s += " "
+		}
+		s += instruction.String()
+	}
+
+	return s, nil
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+)
+
+const (
+	AssetIDLen      = 32
+	MaxAssetNameLen = 32
+	MaxAssetSupply  = 1 << 62
+)
+
+// NewIssuance returns a transaction minting supply units of a new asset to
+// the owner. It spends the given outpoint, which fixes the asset ID; the
+// caller adds any change output and signs the input.
+func NewIssuance(prevTxHash []byte, prevOutIndex uint32, name string, supply int64, owner crypto.Address) (*proto.Transaction, error) {
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   prevTxHash,
+				PrevOutIndex: prevOutIndex,
+			},
+		},
+		Issuance: &proto.AssetIssuance{
+			Amount: supply,
+			Name:   name,
+		},
+	}
+
+	assetID, err := IssuedAssetID(tx)
+	if err != nil {
+		return nil, err
+	}
+	tx.Outputs = append(tx.Outputs, NewAssetOutput(assetID, supply, owner))
+
+	return tx, nil
+}
+
+func NewAssetOutput(assetID []byte, amount int64, address crypto.Address) *proto.TxOutput {
+	return &proto.TxOutput{
+		Amount:  amount,
+		Address: address.Bytes(),
+		AssetId: assetID,
+	}
+}
+
+// IssuedAssetID returns the ID of the asset minted by the issuance, the hash
+// of the first outpoint the transaction spends. An output can only be spent
+// once, so no two issuances share an ID.
+func IssuedAssetID(tx *proto.Transaction) ([]byte, error) {
+	if tx.Issuance == nil {
+		return nil, fmt.Errorf("transaction does not issue an asset")
+	}
+	if len(tx.Inputs) == 0 {
+		return nil, fmt.Errorf("issuance must spend at least one input")
+	}
+
+	input := tx.Inputs[0]
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, input.PrevOutIndex)
+
+	h := sha256.New()
+	h.Write(input.PrevTxHash)
+	h.Write(index)
+
+	return h.Sum(nil), nil
+}
+
+func ValidateIssuance(issuance *proto.AssetIssuance) error {
+	if issuance.Amount <= 0 || issuance.Amount > MaxAssetSupply {
+		return fmt.Errorf("invalid asset supply %d", issuance.Amount)
+	}
+	if len(issuance.Name) > MaxAssetNameLen {
+		return fmt.Errorf("asset name exceeds %d bytes", MaxAssetNameLen)
+	}
+
+	return nil
+}
+
+func ValidateAssetID(assetID []byte) error {
+	if len(assetID) > 0 && len(assetID) != AssetIDLen {
+		return fmt.Errorf("invalid asset id length (%d)", len(assetID))
+	}
+
+	return nil
+}
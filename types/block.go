@@ -3,37 +3,14 @@ package types
 import (
 	"bytes"
 	"crypto/sha256"
-	"github.com/cbergoon/merkletree"
 	"github.com/cmkqwerty/blocker/crypto"
 	"github.com/cmkqwerty/blocker/proto"
 	pb "google.golang.org/protobuf/proto"
 )
 
-type TxHash struct {
-	hash []byte
-}
-
-func NewTxHash(hash []byte) TxHash {
-	return TxHash{hash: hash}
-}
-
-func (t TxHash) CalculateHash() ([]byte, error) {
-	return t.hash, nil
-}
-
-func (t TxHash) Equals(other merkletree.Content) (bool, error) {
-	equals := bytes.Equal(t.hash, other.(TxHash).hash)
-	return equals, nil
-}
-
 func SignBlock(pk *crypto.PrivateKey, block *proto.Block) *crypto.Signature {
 	if len(block.Transactions) > 0 {
-		tree, err := GetMerkleTree(block)
-		if err != nil {
-			panic(err)
-		}
-
-		block.Header.RootHash = tree.MerkleRoot()
+		block.Header.RootHash = CalculateRootHash(block)
 	}
 
 	hash := HashBlock(block)
@@ -84,33 +61,5 @@ func VerifyBlock(block *proto.Block) bool {
 }
 
 func VerifyRootHash(block *proto.Block) bool {
-	tree, err := GetMerkleTree(block)
-	if err != nil {
-		return false
-	}
-
-	valid, err := tree.VerifyTree()
-	if err != nil {
-		return false
-	}
-
-	if !valid {
-		return false
-	}
-
-	return bytes.Equal(block.Header.RootHash, tree.MerkleRoot())
-}
-
-func GetMerkleTree(block *proto.Block) (*merkletree.MerkleTree, error) {
-	list := make([]merkletree.Content, len(block.Transactions))
-	for i := 0; i < len(block.Transactions); i++ {
-		list[i] = NewTxHash(HashTransaction(block.Transactions[i]))
-	}
-
-	tree, err := merkletree.NewTree(list)
-	if err != nil {
-		return nil, err
-	}
-
-	return tree, nil
+	return bytes.Equal(block.Header.RootHash, CalculateRootHash(block))
 }
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/cmkqwerty/blocker/proto"
+)
+
+// MaxDataSize caps the payload of a data output, enough for a hash and some
+// metadata but too small to use the chain as file storage.
+const MaxDataSize = 80
+
+// NewDataOutput returns an unspendable output carrying the payload, e.g. the
+// hash of a document to timestamp.
+func NewDataOutput(data []byte) *proto.TxOutput {
+	return &proto.TxOutput{
+		Data: data,
+	}
+}
+
+func IsDataOutput(output *proto.TxOutput) bool {
+	return len(output.Data) > 0
+}
+
+func ValidateDataOutput(output *proto.TxOutput) error {
+	if len(output.Data) > MaxDataSize {
+		return fmt.Errorf("data size %d exceeds limit %d", len(output.Data), MaxDataSize)
+	}
+	if output.Amount != 0 {
+		return fmt.Errorf("data output must not carry an amount")
+	}
+	if len(output.Address) > 0 || output.Multisig != nil || len(output.LockingScript) > 0 {
+		return fmt.Errorf("data output must not have a lock")
+	}
+
+	return nil
+}
+
+// HashData returns the hash anchors of the payload are looked up by.
+func HashData(data []byte) []byte {
+	hash := sha256.Sum256(data)
+
+	return hash[:]
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/script"
+)
+
+// NewHTLCOutput returns an output the recipient can claim by revealing the
+// preimage of hash and the refund key can take back once the chain reached
+// the timeout height or time.
+func NewHTLCOutput(amount int64, hash []byte, recipient *crypto.PublicKey, refund *crypto.PublicKey, timeout int64) *proto.TxOutput {
+	return &proto.TxOutput{
+		Amount:        amount,
+		LockingScript: script.HTLC(hash, recipient, refund, timeout),
+	}
+}
+
+// ClaimHTLC signs the input spending an HTLC output with the recipient key
+// and reveals the preimage. All other fields of the transaction must be
+// final, as the signature commits to them.
+func ClaimHTLC(pk *crypto.PrivateKey, tx *proto.Transaction, inputIndex int, preimage []byte) error {
+	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
+		return fmt.Errorf("transaction has no input %d", inputIndex)
+	}
+
+	signature := SignTransaction(pk, tx)
+	tx.Inputs[inputIndex].UnlockingScript = script.HTLCClaimUnlock(signature, preimage)
+
+	return nil
+}
+
+// RefundHTLC sets the lock time of the transaction to the timeout and signs
+// the input spending an HTLC output with the refund key. The transaction is
+// held by the mempool until the timeout passes.
+func RefundHTLC(pk *crypto.PrivateKey, tx *proto.Transaction, inputIndex int, timeout int64) error {
+	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
+		return fmt.Errorf("transaction has no input %d", inputIndex)
+	}
+
+	tx.LockTime = timeout
+	signature := SignTransaction(pk, tx)
+	tx.Inputs[inputIndex].UnlockingScript = script.HTLCRefundUnlock(signature)
+
+	return nil
+}
+
+// ExtractHTLCPreimage returns the preimage revealed by an input claiming an
+// HTLC output, which lets the counterparty of a swap claim its side.
+func ExtractHTLCPreimage(input *proto.TxInput) ([]byte, error) {
+	instructions, err := script.Parse(input.UnlockingScript)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instructions) != 3 || instructions[2].Op != script.OP_TRUE {
+		return nil, fmt.Errorf("input does not claim an HTLC")
+	}
+
+	return instructions[1].Data, nil
+}
@@ -0,0 +1,138 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/cmkqwerty/blocker/proto"
+)
+
+// Leaves and inner nodes are hashed with different prefixes (RFC 6962), so an
+// inner node can never be passed off as a leaf. Odd nodes are promoted instead
+// of being paired with a copy of themselves, which means no two different
+// transaction lists share the same root.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+func hashLeaf(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{leafPrefix}, data...))
+
+	return hash[:]
+}
+
+func hashNode(left, right []byte) []byte {
+	b := make([]byte, 0, 1+len(left)+len(right))
+	b = append(b, nodePrefix)
+	b = append(b, left...)
+	b = append(b, right...)
+	hash := sha256.Sum256(b)
+
+	return hash[:]
+}
+
+// splitPoint returns the largest power of two smaller than n.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// MerkleRoot returns the root of the merkle tree built over the given leaves.
+func MerkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	case 1:
+		return hashLeaf(leaves[0])
+	}
+
+	k := splitPoint(len(leaves))
+
+	return hashNode(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+func merklePath(index int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return [][]byte{}
+	}
+
+	k := splitPoint(len(leaves))
+	if index < k {
+		return append(merklePath(index, leaves[:k]), MerkleRoot(leaves[k:]))
+	}
+
+	return append(merklePath(index-k, leaves[k:]), MerkleRoot(leaves[:k]))
+}
+
+func transactionHashes(block *proto.Block) [][]byte {
+	hashes := make([][]byte, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		hashes[i] = HashTransaction(tx)
+	}
+
+	return hashes
+}
+
+// CalculateRootHash returns the merkle root of the block transactions.
+func CalculateRootHash(block *proto.Block) []byte {
+	return MerkleRoot(transactionHashes(block))
+}
+
+// NewTxProof returns the merkle path proving that the transaction with the
+// given hash is included in the block.
+func NewTxProof(block *proto.Block, txHash []byte) (*proto.MerkleProof, error) {
+	hashes := transactionHashes(block)
+	for i, hash := range hashes {
+		if !bytes.Equal(hash, txHash) {
+			continue
+		}
+
+		return &proto.MerkleProof{
+			TxHash: txHash,
+			Index:  uint32(i),
+			Total:  uint32(len(hashes)),
+			Path:   merklePath(i, hashes),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("transaction [%x] is not in block [%x]", txHash, HashBlock(block))
+}
+
+// VerifyTxProof checks the merkle path of the proof against the given root hash.
+func VerifyTxProof(rootHash []byte, proof *proto.MerkleProof) bool {
+	if proof == nil || proof.Index >= proof.Total {
+		return false
+	}
+
+	var (
+		fn   = proof.Index
+		sn   = proof.Total - 1
+		hash = hashLeaf(proof.TxHash)
+	)
+	for _, sibling := range proof.Path {
+		if sn == 0 {
+			return false
+		}
+
+		if fn&1 == 1 || fn == sn {
+			hash = hashNode(sibling, hash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = hashNode(hash, sibling)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(hash, rootHash)
+}
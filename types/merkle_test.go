@@ -0,0 +1,84 @@
+package types
+
+import (
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func randomLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		leaves[i] = util.RandomHash()
+	}
+
+	return leaves
+}
+
+func TestMerkleRootDuplicateLastLeaf(t *testing.T) {
+	leaves := randomLeaves(3)
+	duplicated := append(leaves, leaves[2])
+
+	assert.NotEqual(t, MerkleRoot(leaves), MerkleRoot(duplicated))
+}
+
+func TestMerkleRootLeafIsNotNode(t *testing.T) {
+	leaves := randomLeaves(2)
+	inner := hashNode(hashLeaf(leaves[0]), hashLeaf(leaves[1]))
+
+	assert.NotEqual(t, MerkleRoot(leaves), MerkleRoot([][]byte{inner}))
+}
+
+func TestTxProof(t *testing.T) {
+	privateKey := crypto.GeneratePrivateKey()
+
+	for n := 1; n <= 17; n++ {
+		block := util.RandomBlock()
+		for i := 0; i < n; i++ {
+			block.Transactions = append(block.Transactions, &proto.Transaction{
+				Version: 1,
+				Outputs: []*proto.TxOutput{{Amount: int64(i)}},
+			})
+		}
+		SignBlock(privateKey, block)
+
+		for i, tx := range block.Transactions {
+			proof, err := NewTxProof(block, HashTransaction(tx))
+			require.Nil(t, err)
+			assert.Equal(t, uint32(i), proof.Index)
+			assert.Equal(t, uint32(n), proof.Total)
+			assert.True(t, VerifyTxProof(block.Header.RootHash, proof))
+
+			proof.Index = (proof.Index + 1) % proof.Total
+			if n > 1 {
+				assert.False(t, VerifyTxProof(block.Header.RootHash, proof))
+			}
+		}
+	}
+}
+
+func TestTxProofInvalid(t *testing.T) {
+	privateKey := crypto.GeneratePrivateKey()
+	block := util.RandomBlock()
+	for i := 0; i < 5; i++ {
+		block.Transactions = append(block.Transactions, &proto.Transaction{Version: int32(i)})
+	}
+	SignBlock(privateKey, block)
+
+	_, err := NewTxProof(block, util.RandomHash())
+	assert.NotNil(t, err)
+
+	proof, err := NewTxProof(block, HashTransaction(block.Transactions[3]))
+	require.Nil(t, err)
+
+	assert.False(t, VerifyTxProof(util.RandomHash(), proof))
+
+	proof.Path[0] = util.RandomHash()
+	assert.False(t, VerifyTxProof(block.Header.RootHash, proof))
+
+	proof.TxHash = util.RandomHash()
+	assert.False(t, VerifyTxProof(block.Header.RootHash, proof))
+}
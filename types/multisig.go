@@ -0,0 +1,119 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"sort"
+)
+
+const MaxMultisigKeys = 16
+
+func NewMultisigLock(threshold uint32, publicKeys ...*crypto.PublicKey) *proto.MultisigLock {
+	lock := &proto.MultisigLock{
+		Threshold:  threshold,
+		PublicKeys: make([][]byte, len(publicKeys)),
+	}
+	for i, publicKey := range publicKeys {
+		lock.PublicKeys[i] = publicKey.Bytes()
+	}
+
+	return lock
+}
+
+func NewMultisigOutput(amount int64, lock *proto.MultisigLock) *proto.TxOutput {
+	return &proto.TxOutput{
+		Amount:   amount,
+		Multisig: lock,
+	}
+}
+
+func ValidateMultisigLock(lock *proto.MultisigLock) error {
+	n := len(lock.PublicKeys)
+	if n == 0 || n > MaxMultisigKeys {
+		return fmt.Errorf("multisig lock must have between 1 and %d keys, got %d", MaxMultisigKeys, n)
+	}
+	if lock.Threshold == 0 || int(lock.Threshold) > n {
+		return fmt.Errorf("invalid multisig threshold %d of %d", lock.Threshold, n)
+	}
+
+	for i, publicKey := range lock.PublicKeys {
+		if len(publicKey) != crypto.PublicKeyLen {
+			return fmt.Errorf("invalid length of multisig key %d", i)
+		}
+		for _, other := range lock.PublicKeys[:i] {
+			if bytes.Equal(publicKey, other) {
+				return fmt.Errorf("duplicate multisig key %d", i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CosignInput adds the signature of the private key to the input spending an
+// output locked by the multisig lock. Co-signers can sign in any order.
+func CosignInput(pk *crypto.PrivateKey, tx *proto.Transaction, inputIndex int, lock *proto.MultisigLock) error {
+	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
+		return fmt.Errorf("transaction has no input %d", inputIndex)
+	}
+
+	keyIndex := -1
+	for i, publicKey := range lock.PublicKeys {
+		if bytes.Equal(publicKey, pk.Public().Bytes()) {
+			keyIndex = i
+			break
+		}
+	}
+	if keyIndex < 0 {
+		return fmt.Errorf("key is not part of the multisig lock")
+	}
+
+	input := tx.Inputs[inputIndex]
+	for _, signature := range input.MultisigSignatures {
+		if signature.KeyIndex == uint32(keyIndex) {
+			return fmt.Errorf("input %d already signed by key %d", inputIndex, keyIndex)
+		}
+	}
+
+	signature := pk.Sign(HashTransactionForSigning(tx))
+	input.MultisigSignatures = append(input.MultisigSignatures, &proto.InputSignature{
+		KeyIndex:  uint32(keyIndex),
+		Signature: signature.Bytes(),
+	})
+	sort.Slice(input.MultisigSignatures, func(i, j int) bool {
+		return input.MultisigSignatures[i].KeyIndex < input.MultisigSignatures[j].KeyIndex
+	})
+
+	return nil
+}
+
+// VerifyMultisigInput checks that the input carries valid signatures of at
+// least threshold distinct keys of the lock.
+func VerifyMultisigInput(hash []byte, input *proto.TxInput, lock *proto.MultisigLock) bool {
+	if ValidateMultisigLock(lock) != nil {
+		return false
+	}
+
+	signed := make(map[uint32]bool)
+	for _, signature := range input.MultisigSignatures {
+		if int(signature.KeyIndex) >= len(lock.PublicKeys) || signed[signature.KeyIndex] {
+			return false
+		}
+		if len(signature.Signature) != crypto.SignatureLen {
+			return false
+		}
+
+		var (
+			sig       = crypto.SignatureFromBytes(signature.Signature)
+			publicKey = crypto.PublicKeyFromBytes(lock.PublicKeys[signature.KeyIndex])
+		)
+		if !sig.Verify(publicKey, hash) {
+			return false
+		}
+		signed[signature.KeyIndex] = true
+	}
+
+	return len(signed) >= int(lock.Threshold)
+}
@@ -0,0 +1,61 @@
+package types
+
+import (
+	"github.com/cmkqwerty/blocker/crypto"
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCosignInput(t *testing.T) {
+	var (
+		alice = crypto.GeneratePrivateKey()
+		bob   = crypto.GeneratePrivateKey()
+		carol = crypto.GeneratePrivateKey()
+		lock  = NewMultisigLock(2, alice.Public(), bob.Public(), carol.Public())
+	)
+	require.Nil(t, ValidateMultisigLock(lock))
+
+	tx := &proto.Transaction{
+		Version: 1,
+		Inputs: []*proto.TxInput{
+			{
+				PrevTxHash:   util.RandomHash(),
+				PrevOutIndex: 0,
+			},
+		},
+		Outputs: []*proto.TxOutput{
+			{
+				Amount:  100,
+				Address: alice.Public().Address().Bytes(),
+			},
+		},
+	}
+
+	require.Nil(t, CosignInput(carol, tx, 0, lock))
+	assert.NotNil(t, CosignInput(carol, tx, 0, lock))
+	assert.False(t, VerifyMultisigInput(HashTransactionForSigning(tx), tx.Inputs[0], lock))
+
+	require.Nil(t, CosignInput(alice, tx, 0, lock))
+	assert.True(t, VerifyMultisigInput(HashTransactionForSigning(tx), tx.Inputs[0], lock))
+	assert.Equal(t, uint32(0), tx.Inputs[0].MultisigSignatures[0].KeyIndex)
+
+	assert.NotNil(t, CosignInput(crypto.GeneratePrivateKey(), tx, 0, lock))
+	assert.NotNil(t, CosignInput(bob, tx, 1, lock))
+
+	// duplicated signatures of one key do not count twice
+	tx.Inputs[0].MultisigSignatures[1] = tx.Inputs[0].MultisigSignatures[0]
+	assert.False(t, VerifyMultisigInput(HashTransactionForSigning(tx), tx.Inputs[0], lock))
+}
+
+func TestValidateMultisigLock(t *testing.T) {
+	key := crypto.GeneratePrivateKey().Public()
+
+	assert.NotNil(t, ValidateMultisigLock(NewMultisigLock(1)))
+	assert.NotNil(t, ValidateMultisigLock(NewMultisigLock(0, key)))
+	assert.NotNil(t, ValidateMultisigLock(NewMultisigLock(2, key)))
+	assert.NotNil(t, ValidateMultisigLock(NewMultisigLock(1, key, key)))
+	assert.Nil(t, ValidateMultisigLock(NewMultisigLock(1, key)))
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/cmkqwerty/blocker/script"
+)
+
+const (
+	// LockTimeThreshold separates lock times given as block heights (below)
+	// from unix timestamps in seconds (at or above).
+	LockTimeThreshold = script.LockTimeThreshold
+
+	// Input sequences encode relative locks: the low 16 bits hold the value,
+	// counted in blocks or, with SequenceTypeFlag set, in units of
+	// SequenceGranularity seconds. SequenceDisableFlag turns the lock off.
+	SequenceDisableFlag = 1 << 31
+	SequenceTypeFlag    = 1 << 22
+	SequenceMask        = 0x0000ffff
+	SequenceGranularity = 512
+)
+
+// NewSequence returns an input sequence that locks the spent output until
+// it has the given number of blocks on top of the block including it.
+func NewSequence(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// NewTimeSequence returns an input sequence that locks the spent output
+// until the given number of seconds, rounded up to SequenceGranularity,
+// passed since the block including it.
+func NewTimeSequence(seconds int64) uint32 {
+	units := (seconds + SequenceGranularity - 1) / SequenceGranularity
+	if units > SequenceMask {
+		units = SequenceMask
+	}
+
+	return SequenceTypeFlag | uint32(units)
+}
+
+// IsFinal reports whether the lock time of the transaction allows it to be
+// included on top of a tip with the given height and time.
+func IsFinal(tx *proto.Transaction, height int64, time int64) bool {
+	switch {
+	case tx.LockTime <= 0:
+		return true
+	case tx.LockTime < LockTimeThreshold:
+		return tx.LockTime <= height
+	default:
+		return tx.LockTime <= time
+	}
+}
+
+// IsSequenceFinal reports whether the relative lock of the input is satisfied
+// for an output confirmed at prevHeight and prevTime, when spent on top of a
+// tip with the given height and time.
+func IsSequenceFinal(sequence uint32, prevHeight int64, prevTime int64, height int64, time int64) bool {
+	if sequence&SequenceDisableFlag != 0 {
+		return true
+	}
+
+	value := int64(sequence & SequenceMask)
+	if sequence&SequenceTypeFlag != 0 {
+		return time-prevTime >= value*SequenceGranularity
+	}
+
+	return height+1-prevHeight >= value
+}
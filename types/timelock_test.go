@@ -0,0 +1,33 @@
+package types
+
+import (
+	"github.com/cmkqwerty/blocker/proto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsFinal(t *testing.T) {
+	tx := &proto.Transaction{Version: 1}
+	assert.True(t, IsFinal(tx, 0, 0))
+
+	tx.LockTime = 10
+	assert.False(t, IsFinal(tx, 9, 1_700_000_000))
+	assert.True(t, IsFinal(tx, 10, 0))
+
+	tx.LockTime = 1_700_000_000
+	assert.False(t, IsFinal(tx, 1_800_000_000, 1_699_999_999))
+	assert.True(t, IsFinal(tx, 0, 1_700_000_000))
+}
+
+func TestIsSequenceFinal(t *testing.T) {
+	sequence := NewSequence(3)
+	assert.False(t, IsSequenceFinal(sequence, 10, 0, 11, 0))
+	assert.True(t, IsSequenceFinal(sequence, 10, 0, 12, 0))
+
+	sequence = NewTimeSequence(1000)
+	assert.Equal(t, uint32(SequenceTypeFlag|2), sequence)
+	assert.False(t, IsSequenceFinal(sequence, 10, 5000, 100, 6000))
+	assert.True(t, IsSequenceFinal(sequence, 10, 5000, 11, 6024))
+
+	assert.True(t, IsSequenceFinal(SequenceDisableFlag|sequence, 10, 5000, 10, 5000))
+}
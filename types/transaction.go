@@ -7,8 +7,12 @@ import (
 	pb "google.golang.org/protobuf/proto"
 )
 
+// MaxMoney caps the amount of an output and what a transaction spends or
+// creates of any asset, far below the range where sums could overflow.
+const MaxMoney = 1 << 62
+
 func SignTransaction(pk *crypto.PrivateKey, tx *proto.Transaction) *crypto.Signature {
-	return pk.Sign(HashTransaction(tx))
+	return pk.Sign(HashTransactionForSigning(tx))
 }
 
 func HashTransaction(tx *proto.Transaction) []byte {
@@ -21,20 +25,45 @@ func HashTransaction(tx *proto.Transaction) []byte {
 	return hash[:]
 }
 
+// HashTransactionForSigning returns the hash of the transaction with all input
+// signatures stripped, which is the message every input signs.
+func HashTransactionForSigning(tx *proto.Transaction) []byte {
+	clone := pb.Clone(tx).(*proto.Transaction)
+	for _, input := range clone.Inputs {
+		input.Signature = nil
+		input.MultisigSignatures = nil
+		input.UnlockingScript = nil
+	}
+
+	return HashTransaction(clone)
+}
+
+// VerifyInput checks the single signature of an input against the signing
+// hash of its transaction.
+func VerifyInput(hash []byte, input *proto.TxInput) bool {
+	if len(input.Signature) != crypto.SignatureLen || len(input.PublicKey) != crypto.PublicKeyLen {
+		return false
+	}
+
+	var (
+		signature = crypto.SignatureFromBytes(input.Signature)
+		publicKey = crypto.PublicKeyFromBytes(input.PublicKey)
+	)
+
+	return signature.Verify(publicKey, hash)
+}
+
+// VerifyTransaction checks the signatures of all single signature inputs.
+// Inputs spending multisig or script outputs are verified against the spent
+// output with VerifyMultisigInput or the script package.
 func VerifyTransaction(tx *proto.Transaction) bool {
+	hash := HashTransactionForSigning(tx)
 	for _, input := range tx.Inputs {
-		if len(input.Signature) == 0 {
-			panic("transaction with no signature")
+		if len(input.MultisigSignatures) > 0 || len(input.UnlockingScript) > 0 {
+			continue
 		}
 
-		var (
-			signature = crypto.SignatureFromBytes(input.Signature)
-			publicKey = crypto.PublicKeyFromBytes(input.PublicKey)
-		)
-
-		// TODO: make sure dont run into problems after verify
-		input.Signature = nil
-		if !signature.Verify(publicKey, HashTransaction(tx)) {
+		if !VerifyInput(hash, input) {
 			return false
 		}
 	}